@@ -2,8 +2,13 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,14 +17,13 @@ import (
 )
 
 func handlerLogin(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("the login command requires a username")
-	}
-
 	_, err := s.db.GetUser(
 		context.Background(),
 		cmd.args[0],
 	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user named %s", cmd.args[0])
+	}
 	if err != nil {
 		return err
 	}
@@ -35,10 +39,6 @@ func handlerLogin(s *state, cmd command) error {
 }
 
 func handlerRegister(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("the login command requires a username")
-	}
-
 	user, err := s.db.CreateUser(
 		context.Background(),
 		database.CreateUserParams{
@@ -58,14 +58,26 @@ func handlerRegister(s *state, cmd command) error {
 		return err
 	}
 
-	fmt.Println("User registered successfully")
-	fmt.Printf("New user: %v\n", user)
+	row := userRow{
+		Name:      user.Name,
+		Current:   true,
+		CreatedAt: user.CreatedAt,
+	}
 
-	return nil
+	return printListing(cmd, row, func() error {
+		fmt.Println("User registered successfully")
+		fmt.Printf("Logged in as %s\n", row.Name)
+		return nil
+	})
 }
 
 func handlerReset(s *state, cmd command) error {
-	err := s.db.ResetUsers(context.Background())
+	err := s.db.ResetFeeds(context.Background())
+	if err != nil {
+		return err
+	}
+
+	err = s.db.ResetUsers(context.Background())
 	if err != nil {
 		return err
 	}
@@ -79,22 +91,100 @@ func handlerUsers(s *state, cmd command) error {
 		return err
 	}
 
-	for _, user := range users {
-		if user.Name == *s.cfg.CurrentUserName {
-			fmt.Printf("* %s (current)\n", user.Name)
-		} else {
-			fmt.Printf("* %s\n", user.Name)
+	rows := make([]userRow, len(users))
+	for i, user := range users {
+		rows[i] = userRow{
+			Name:      user.Name,
+			Current:   s.cfg.CurrentUserName != nil && user.Name == *s.cfg.CurrentUserName,
+			CreatedAt: user.CreatedAt,
 		}
 	}
 
-	return nil
+	return printListing(cmd, rows, func() error {
+		for _, row := range rows {
+			if row.Current {
+				fmt.Printf("* %s (current)\n", row.Name)
+			} else {
+				fmt.Printf("* %s\n", row.Name)
+			}
+		}
+		return nil
+	})
 }
 
-func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("the agg command requires a time period")
+// handlerUserDelete deletes a user and their follows. Feeds the user added
+// are handed over to their earliest remaining follower; feeds nobody else
+// follows are kept without an owner so their posts survive.
+func handlerUserDelete(s *state, cmd command) error {
+	user, err := s.db.GetUser(context.Background(), cmd.args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user named %s", cmd.args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	err = withTx(s, func(tx *state) error {
+		feeds, err := tx.db.GetFeedsOwnedByUser(
+			context.Background(),
+			uuid.NullUUID{UUID: user.ID, Valid: true},
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, feed := range feeds {
+			nextOwner, err := tx.db.GetNextFeedFollower(
+				context.Background(),
+				database.GetNextFeedFollowerParams{
+					FeedID: feed.ID,
+					UserID: user.ID,
+				},
+			)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			err = tx.db.SetFeedOwner(
+				context.Background(),
+				database.SetFeedOwnerParams{
+					ID:        feed.ID,
+					UserID:    uuid.NullUUID{UUID: nextOwner, Valid: true},
+					UpdatedAt: time.Now(),
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.db.DeleteUser(context.Background(), user.ID)
+		if err != nil {
+			return err
+		}
+
+		return updateOrphanedFeeds(tx)
+	})
+	if err != nil {
+		return err
 	}
 
+	if s.cfg.CurrentUserName != nil && *s.cfg.CurrentUserName == user.Name {
+		err = s.cfg.ClearUser()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Deleted user %s\n", user.Name)
+
+	return nil
+}
+
+func handlerAgg(s *state, cmd command) error {
 	timeBetweenRequests, err := time.ParseDuration(cmd.args[0])
 	if err != nil {
 		return err
@@ -102,193 +192,1482 @@ func handlerAgg(s *state, cmd command) error {
 
 	fmt.Printf("Collecting feeds every %s\n", timeBetweenRequests)
 
+	var lastPrunedAt time.Time
+
 	ticker := time.NewTicker(timeBetweenRequests)
 	for ; ; <-ticker.C {
+		if time.Since(lastPrunedAt) >= pruneInterval {
+			pruned, err := prunePosts(s)
+			if err != nil {
+				fmt.Printf("Error pruning posts: %v\n", err)
+			} else if pruned > 0 {
+				fmt.Printf("Pruned %d old post(s)\n", pruned)
+			}
+			lastPrunedAt = time.Now()
+		}
+
 		scrapeFeeds(s)
 	}
 }
 
-func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.args) <= 1 {
-		return fmt.Errorf("the add command requires a feed URL and a name")
-	}
-
-	name := cmd.args[0]
-	url := cmd.args[1]
+type gcOptions struct {
+	grace  *time.Duration
+	dryRun bool
+}
 
-	feed, err := s.db.CreateFeed(
-		context.Background(),
-		database.CreateFeedParams{
-			ID:        uuid.New(),
-			Name:      name,
-			Url:       url,
-			UserID:    user.ID,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		},
-	)
+// handlerGC deletes feeds that have had no followers for longer than the
+// grace period, along with their posts. Feeds with starred posts are kept.
+func handlerGC(s *state, cmd command, opts gcOptions) error {
+	gracePeriod, err := s.cfg.GCGracePeriod()
 	if err != nil {
 		return err
 	}
 
-	_, err = s.db.CreateFeedFollow(
-		context.Background(),
-		database.CreateFeedFollowParams{
-			ID:        uuid.New(),
-			FeedID:    feed.ID,
-			UserID:    user.ID,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		},
-	)
+	if opts.grace != nil {
+		gracePeriod = *opts.grace
+	}
+
+	cutoff := sql.NullTime{Time: time.Now().Add(-gracePeriod), Valid: true}
+
+	if opts.dryRun {
+		feeds, err := s.db.GetFeedsOrphanedBefore(context.Background(), cutoff)
+		if err != nil {
+			return err
+		}
+
+		if len(feeds) == 0 {
+			fmt.Println("No feeds to delete")
+			return nil
+		}
+
+		for _, feed := range feeds {
+			fmt.Printf(
+				"* %s (%s), unfollowed since %s, %d post(s)\n",
+				feed.Name,
+				feed.Url,
+				feed.OrphanedAt.Time.Format(time.DateOnly),
+				feed.PostCount,
+			)
+		}
+		fmt.Printf("\n%d feed(s) would be deleted\n", len(feeds))
+
+		return nil
+	}
+
+	count, err := s.db.DeleteFeedsOrphanedBefore(context.Background(), cutoff)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(feed)
+	fmt.Printf("Deleted %d unfollowed feed(s)\n", count)
 
 	return nil
 }
 
-func handlerFeeds(s *state, cmd command) error {
-	feeds, err := s.db.GetFeeds(context.Background())
+// pruneInterval is how often the aggregator applies the retention policies.
+const pruneInterval = time.Hour
+
+type pruneOptions struct {
+	dryRun bool
+}
+
+func handlerPrune(s *state, cmd command, opts pruneOptions) error {
+	if !opts.dryRun {
+		count, err := prunePosts(s)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Deleted %d post(s)\n", count)
+		return nil
+	}
+
+	posts, err := getPrunablePosts(s)
 	if err != nil {
 		return err
 	}
 
-	for _, feed := range feeds {
-		fmt.Printf("Name: %s\n", feed.Name)
-		fmt.Printf("URL: %s\n", feed.Url)
-		fmt.Printf("Created by: %s\n", feed.UserName)
-		fmt.Println("---------------")
+	if len(posts) == 0 {
+		fmt.Println("No posts to delete")
+		return nil
+	}
+
+	counts := make(map[string]int)
+	var feedURLs []string
+	names := make(map[string]string)
+	for _, post := range posts {
+		if _, ok := counts[post.FeedUrl]; !ok {
+			feedURLs = append(feedURLs, post.FeedUrl)
+			names[post.FeedUrl] = post.FeedName
+		}
+		counts[post.FeedUrl]++
+	}
+
+	for _, url := range feedURLs {
+		fmt.Printf("* %s (%s): %d post(s)\n", names[url], url, counts[url])
 	}
+	fmt.Printf("\n%d post(s) would be deleted\n", len(posts))
 
 	return nil
 }
 
-func handlerFollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("the follow command requires a feed URL")
+// prunePosts deletes the posts that fall outside their feed's retention
+// policy, or the global one from the config. Starred posts are never pruned.
+func prunePosts(s *state) (int64, error) {
+	posts, err := getPrunablePosts(s)
+	if err != nil {
+		return 0, err
 	}
 
-	url := cmd.args[0]
+	if len(posts) == 0 {
+		return 0, nil
+	}
 
-	feed, err := s.db.GetFeedByUrl(
+	ids := make([]uuid.UUID, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+
+	return s.db.DeletePosts(context.Background(), ids)
+}
+
+func getPrunablePosts(s *state) ([]database.GetPrunablePostsRow, error) {
+	maxAge, err := s.cfg.RetentionMaxAgeDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.db.GetPrunablePosts(
 		context.Background(),
-		url,
+		database.GetPrunablePostsParams{
+			Now: time.Now(),
+			DefaultMaxAgeSeconds: sql.NullInt64{
+				Int64: int64(maxAge.Seconds()),
+				Valid: maxAge > 0,
+			},
+			DefaultMaxPosts: sql.NullInt32{
+				Int32: int32(s.cfg.RetentionMaxPosts),
+				Valid: s.cfg.RetentionMaxPosts > 0,
+			},
+		},
 	)
+}
+
+// feedRetentionMaxAge returns how long posts of feed are kept, or zero if
+// they are kept regardless of their age. Posts are kept for the follower who
+// keeps them the longest.
+func feedRetentionMaxAge(s *state, feed database.Feed) (time.Duration, error) {
+	defaultMaxAge, err := s.cfg.RetentionMaxAgeDuration()
+	if err != nil {
+		return 0, err
+	}
+
+	retentions, err := s.db.GetFeedFollowRetentions(context.Background(), feed.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(retentions) == 0 {
+		return defaultMaxAge, nil
+	}
+
+	var maxAge time.Duration
+	for _, retention := range retentions {
+		age := defaultMaxAge
+		if retention.RetentionMaxAgeSeconds.Valid {
+			age = time.Duration(retention.RetentionMaxAgeSeconds.Int64) * time.Second
+		}
+		if age == 0 {
+			return 0, nil
+		}
+		maxAge = max(maxAge, age)
+	}
+
+	return maxAge, nil
+}
+
+// updateOrphanedFeeds records when feeds lost their last follower, and
+// forgets it for feeds that have been followed again. Commands call it in the
+// same transaction as the follows they create or delete, so the grace period
+// of gc starts when the last follower left.
+func updateOrphanedFeeds(s *state) error {
+	_, err := s.db.ClearFollowedFeedsOrphanedAt(context.Background())
 	if err != nil {
 		return err
 	}
 
-	feed_follow, err := s.db.CreateFeedFollow(
+	_, err = s.db.MarkOrphanedFeeds(
 		context.Background(),
-		database.CreateFeedFollowParams{
-			ID:        uuid.New(),
-			FeedID:    feed.ID,
-			UserID:    user.ID,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		},
+		sql.NullTime{Time: time.Now(), Valid: true},
 	)
+	return err
+}
+
+func handlerAddFeed(s *state, cmd command, user database.User) error {
+	name, url := "", cmd.args[0]
+	if len(cmd.args) > 1 {
+		name, url = cmd.args[0], cmd.args[1]
+	}
+
+	existing, err := s.db.GetFeedByUrl(context.Background(), url)
+	if err == nil {
+		var followed bool
+		err = withTx(s, func(tx *state) error {
+			var err error
+			followed, err = followFeed(tx, user, existing)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		return printAddedFeed(cmd, user, existing, false, followed)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var data *RSSFeed
+	if name == "" {
+		data, err = fetchFeed(context.Background(), url)
+		if err != nil {
+			return err
+		}
+
+		name = strings.TrimSpace(data.Channel.Title)
+		if name == "" {
+			name = url
+		}
+	}
+
+	var feed database.Feed
+	err = withTx(s, func(tx *state) error {
+		var err error
+		feed, err = tx.db.CreateFeed(
+			context.Background(),
+			database.CreateFeedParams{
+				ID:        uuid.New(),
+				Name:      name,
+				Url:       url,
+				UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.db.CreateFeedFollow(
+			context.Background(),
+			database.CreateFeedFollowParams{
+				ID:        uuid.New(),
+				FeedID:    feed.ID,
+				UserID:    user.ID,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		if data != nil {
+			feed, err = updateFeedMetadata(tx, feed.ID, data)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf(
-		"User %s followed the feed %s successfully!\n",
-		feed_follow.UserName,
-		feed_follow.FeedName,
-	)
+	return printAddedFeed(cmd, user, feed, true, true)
+}
 
-	return nil
+func printAddedFeed(cmd command, user database.User, feed database.Feed, added, followed bool) error {
+	row := addedFeedRow{
+		Name:     feed.Name,
+		URL:      feed.Url,
+		SiteLink: nullStringPtr(feed.SiteLink),
+		Added:    added,
+		Followed: followed,
+	}
+
+	return printListing(cmd, row, func() error {
+		if added {
+			fmt.Printf("Added the feed %s (%s)\n", feed.Name, feed.Url)
+		} else {
+			fmt.Printf("The feed %s has already been added as %s\n", feed.Url, feed.Name)
+		}
+		printFollow(user, feed, followed)
+		return nil
+	})
 }
 
-func handlerFollowing(s *state, cmd command, user database.User) error {
-	feed_follows, err := s.db.GetFeedFollowsForUser(
-		context.Background(),
-		user.ID,
-	)
+func handlerFeeds(s *state, cmd command) error {
+	feeds, err := s.db.GetFeeds(context.Background())
 	if err != nil {
 		return err
 	}
 
-	if len(feed_follows) == 0 {
-		fmt.Println("You are not following any feeds")
+	rows := make([]feedRow, len(feeds))
+	for i, feed := range feeds {
+		rows[i] = feedRow{
+			Name:          feed.Name,
+			URL:           feed.Url,
+			CreatedBy:     nullStringPtr(feed.UserName),
+			SiteLink:      nullStringPtr(feed.SiteLink),
+			LastFetchedAt: nullTimePtr(feed.LastFetchedAt),
+			CreatedAt:     feed.CreatedAt,
+		}
+	}
+
+	return printListing(cmd, rows, func() error {
+		if len(rows) == 0 {
+			fmt.Println("No feeds have been added yet")
+			return nil
+		}
+
+		tb := table{header: []string{"NAME", "URL", "ADDED BY", "LAST FETCHED"}}
+		for _, row := range rows {
+			addedBy := "(deleted user)"
+			if row.CreatedBy != nil {
+				addedBy = *row.CreatedBy
+			}
+			lastFetched := "never"
+			if row.LastFetchedAt != nil {
+				lastFetched = row.LastFetchedAt.Local().Format("2006-01-02 15:04")
+			}
+			tb.append(row.Name, row.URL, addedBy, lastFetched)
+		}
+		tb.render(os.Stdout, stdoutTerminal())
 		return nil
+	})
+}
+
+type feedRemoveOptions struct {
+	force bool
+}
+
+// handlerFeedRemove removes a feed added by the user. Feeds other users still
+// follow are handed over to their earliest follower instead of being deleted,
+// unless --force is given, in which case the feed is deleted along with its
+// posts and everyone's follows.
+func handlerFeedRemove(s *state, cmd command, user database.User, opts feedRemoveOptions) error {
+	var feed database.Feed
+	handedOver := false
+
+	err := withTx(s, func(tx *state) error {
+		var err error
+		feed, err = getOwnedFeed(tx, user, cmd.args[0])
+		if err != nil {
+			return err
+		}
+
+		if !opts.force {
+			nextOwner, err := tx.db.GetNextFeedFollower(
+				context.Background(),
+				database.GetNextFeedFollowerParams{
+					FeedID: feed.ID,
+					UserID: user.ID,
+				},
+			)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+
+			if err == nil {
+				err = tx.db.SetFeedOwner(
+					context.Background(),
+					database.SetFeedOwnerParams{
+						ID:        feed.ID,
+						UserID:    uuid.NullUUID{UUID: nextOwner, Valid: true},
+						UpdatedAt: time.Now(),
+					},
+				)
+				if err != nil {
+					return err
+				}
+
+				err = tx.db.DeleteFeedFollow(
+					context.Background(),
+					database.DeleteFeedFollowParams{
+						UserID: user.ID,
+						Url:    feed.Url,
+					},
+				)
+				if err != nil {
+					return err
+				}
+
+				handedOver = true
+				return updateOrphanedFeeds(tx)
+			}
+		}
+
+		return tx.db.DeleteFeed(context.Background(), feed.ID)
+	})
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Following feeds:")
-	for _, feed_follow := range feed_follows {
-		fmt.Printf("* %s\n", feed_follow.FeedName)
+	if handedOver {
+		fmt.Printf("%s is still followed by other users, so it was handed over instead of deleted\n", feed.Name)
+		fmt.Println("Use --force to delete it for everyone")
+		return nil
 	}
 
+	fmt.Printf("Deleted %s and its posts\n", feed.Name)
+
 	return nil
 }
 
-func handlerUnfollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("the unfollow command requires a feed URL")
+func handlerFeedRename(s *state, cmd command, user database.User) error {
+	name := strings.TrimSpace(strings.Join(cmd.args[1:], " "))
+	if name == "" {
+		return fmt.Errorf("the feed name can't be empty")
 	}
 
-	url := cmd.args[0]
+	feed, err := getOwnedFeed(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
 
-	err := s.db.DeleteFeedFollow(
+	feed, err = s.db.RenameFeed(
 		context.Background(),
-		database.DeleteFeedFollowParams{
-			UserID: user.ID,
-			Url:    url,
+		database.RenameFeedParams{
+			ID:        feed.ID,
+			Name:      name,
+			UpdatedAt: time.Now(),
 		},
 	)
 	if err != nil {
 		return err
 	}
 
+	fmt.Printf("Renamed %s to %s\n", feed.Url, feed.Name)
+
 	return nil
 }
 
-func handlerBrowse(s *state, cmd command, user database.User) error {
-	limit := int32(2)
-	if len(cmd.args) > 0 {
-		inputLimit, err := strconv.ParseInt(cmd.args[0], 10, 0)
-		if err != nil {
-			fmt.Println("Invalid limit value")
-			return err
-		}
-		limit = int32(inputLimit)
+func handlerFeedSetUrl(s *state, cmd command, user database.User) error {
+	newURL := cmd.args[1]
+	if err := validateFeedURL(newURL); err != nil {
+		return fmt.Errorf("invalid feed URL %s: %w", newURL, err)
+	}
+
+	feed, err := getOwnedFeed(s, user, cmd.args[0])
+	if err != nil {
+		return err
 	}
 
-	posts, err := s.db.GetPostsForUser(
+	feed, err = s.db.SetFeedUrl(
 		context.Background(),
-		database.GetPostsForUserParams{
-			UserID: user.ID,
-			Limit:  limit,
+		database.SetFeedUrlParams{
+			ID:        feed.ID,
+			Url:       newURL,
+			UpdatedAt: time.Now(),
 		},
 	)
 	if err != nil {
 		return err
 	}
 
-	if len(posts) == 0 {
-		fmt.Println("No posts to show")
-		return nil
-	}
+	fmt.Printf("%s now points to %s\n", feed.Name, feed.Url)
 
-	for _, post := range posts {
-		fmt.Printf("Title: %s\n", post.Title)
-		fmt.Printf("Link: %s\n", post.Url)
-		if post.Description.Valid {
-			if len(post.Description.String) > 100 {
-				fmt.Printf("Description: %s...\n", post.Description.String[:100])
-			} else {
-				fmt.Printf("Description: %s\n", post.Description.String)
-			}
-		}
-		if post.PublishedAt.Valid {
-			fmt.Printf("Published at: %s\n", post.PublishedAt.Time)
-		}
-		fmt.Println("---------------")
+	return nil
+}
+
+// feedRetentionOptions are the limits given to gator feed retention, nil when
+// they aren't changed.
+type feedRetentionOptions struct {
+	maxAge   *time.Duration
+	maxPosts *int
+	global   bool
+}
+
+// handlerFeedRetention shows or changes how long the user keeps the posts of
+// a feed they follow. Posts are shared by everyone following the feed, so they
+// are only pruned once they fall outside every follower's policy.
+func handlerFeedRetention(s *state, cmd command, user database.User, opts feedRetentionOptions) error {
+	url := cmd.args[0]
+
+	feed_follow, err := s.db.GetFeedFollowByUrl(
+		context.Background(),
+		database.GetFeedFollowByUrlParams{
+			UserID: user.ID,
+			Url:    url,
+		},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("you are not following %s", url)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.global || opts.maxAge != nil || opts.maxPosts != nil {
+		maxAge := feed_follow.RetentionMaxAgeSeconds
+		maxPosts := feed_follow.RetentionMaxPosts
+		if opts.global {
+			maxAge = sql.NullInt64{}
+			maxPosts = sql.NullInt32{}
+		}
+
+		if opts.maxAge != nil {
+			if *opts.maxAge < 0 {
+				return fmt.Errorf("retention limits can't be negative")
+			}
+			maxAge = sql.NullInt64{Int64: int64(opts.maxAge.Seconds()), Valid: true}
+		}
+
+		if opts.maxPosts != nil {
+			if *opts.maxPosts < 0 {
+				return fmt.Errorf("retention limits can't be negative")
+			}
+			maxPosts = sql.NullInt32{Int32: int32(*opts.maxPosts), Valid: true}
+		}
+
+		feed_follow, err = s.db.SetFeedFollowRetention(
+			context.Background(),
+			database.SetFeedFollowRetentionParams{
+				ID:                     feed_follow.ID,
+				RetentionMaxAgeSeconds: maxAge,
+				RetentionMaxPosts:      maxPosts,
+				UpdatedAt:              time.Now(),
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Retention for %s:\n", url)
+	switch {
+	case !feed_follow.RetentionMaxAgeSeconds.Valid:
+		fmt.Println("Max age: global setting")
+	case feed_follow.RetentionMaxAgeSeconds.Int64 == 0:
+		fmt.Println("Max age: forever")
+	default:
+		fmt.Printf("Max age: %s\n", time.Duration(feed_follow.RetentionMaxAgeSeconds.Int64)*time.Second)
+	}
+	switch {
+	case !feed_follow.RetentionMaxPosts.Valid:
+		fmt.Println("Max posts: global setting")
+	case feed_follow.RetentionMaxPosts.Int32 == 0:
+		fmt.Println("Max posts: unlimited")
+	default:
+		fmt.Printf("Max posts: %d\n", feed_follow.RetentionMaxPosts.Int32)
+	}
+
+	return nil
+}
+
+// getOwnedFeed looks up a feed by URL and makes sure it was added by user.
+func getOwnedFeed(s *state, user database.User, url string) (database.Feed, error) {
+	feed, err := s.db.GetFeedByUrl(context.Background(), url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, fmt.Errorf("no feed with URL %s", url)
+	}
+	if err != nil {
+		return database.Feed{}, err
+	}
+
+	if !feed.UserID.Valid || feed.UserID.UUID != user.ID {
+		return database.Feed{}, fmt.Errorf("only the user who added %s can change it", url)
+	}
+
+	return feed, nil
+}
+
+func handlerFollow(s *state, cmd command, user database.User) error {
+	url := cmd.args[0]
+
+	feed, err := s.db.GetFeedByUrl(
+		context.Background(),
+		url,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no feed has been added with the URL %s, add it with: gator addfeed %s", url, url)
+	}
+	if err != nil {
+		return err
+	}
+
+	var followed bool
+	err = withTx(s, func(tx *state) error {
+		var err error
+		followed, err = followFeed(tx, user, feed)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
+	printFollow(user, feed, followed)
+
+	return nil
+}
+
+// followFeed makes user follow feed, unless they already do, and reports
+// whether a follow was created. It should run in a transaction, since a feed
+// that was followed again is no longer orphaned.
+func followFeed(s *state, user database.User, feed database.Feed) (bool, error) {
+	_, err := s.db.GetFeedFollow(
+		context.Background(),
+		database.GetFeedFollowParams{
+			UserID: user.ID,
+			FeedID: feed.ID,
+		},
+	)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	_, err = s.db.CreateFeedFollow(
+		context.Background(),
+		database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			FeedID:    feed.ID,
+			UserID:    user.ID,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return true, updateOrphanedFeeds(s)
+}
+
+func printFollow(user database.User, feed database.Feed, followed bool) {
+	if followed {
+		fmt.Printf("User %s followed the feed %s successfully!\n", user.Name, feed.Name)
+	} else {
+		fmt.Printf("User %s is already following the feed %s\n", user.Name, feed.Name)
+	}
+}
+
+type followingOptions struct {
+	tag string
+}
+
+func handlerFollowing(s *state, cmd command, user database.User, opts followingOptions) error {
+
+	feed_follows, err := s.db.GetFeedFollowsForUser(
+		context.Background(),
+		database.GetFeedFollowsForUserParams{
+			UserID: user.ID,
+			Tag:    nullString(opts.tag),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]followingRow, len(feed_follows))
+	for i, feed_follow := range feed_follows {
+		rows[i] = followingRow{
+			Name:     feed_follow.FeedName,
+			URL:      feed_follow.FeedUrl,
+			SiteLink: nullStringPtr(feed_follow.FeedSiteLink),
+			Tags:     feed_follow.Tags,
+		}
+		if rows[i].Tags == nil {
+			rows[i].Tags = []string{}
+		}
+	}
+
+	return printListing(cmd, rows, func() error {
+		if len(rows) == 0 {
+			if opts.tag != "" {
+				fmt.Printf("You are not following any feeds tagged %s\n", opts.tag)
+			} else {
+				fmt.Println("You are not following any feeds")
+			}
+			return nil
+		}
+
+		tb := table{header: []string{"NAME", "URL", "TAGS"}}
+		for _, row := range rows {
+			tb.append(row.Name, row.URL, strings.Join(row.Tags, ", "))
+		}
+		tb.render(os.Stdout, stdoutTerminal())
+		return nil
+	})
+}
+
+func handlerUnfollow(s *state, cmd command, user database.User) error {
+	url := cmd.args[0]
+
+	return withTx(s, func(tx *state) error {
+		err := tx.db.DeleteFeedFollow(
+			context.Background(),
+			database.DeleteFeedFollowParams{
+				UserID: user.ID,
+				Url:    url,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		return updateOrphanedFeeds(tx)
+	})
+}
+
+func handlerTitle(s *state, cmd command, user database.User) error {
+	url := cmd.args[0]
+	title := strings.TrimSpace(strings.Join(cmd.args[1:], " "))
+
+	count, err := s.db.SetFeedFollowDisplayName(
+		context.Background(),
+		database.SetFeedFollowDisplayNameParams{
+			UserID:      user.ID,
+			Url:         url,
+			DisplayName: nullString(title),
+			UpdatedAt:   time.Now(),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return fmt.Errorf("you are not following %s", url)
+	}
+
+	if title == "" {
+		fmt.Printf("Reset the title of %s\n", url)
+	} else {
+		fmt.Printf("Renamed %s to %s for you\n", url, title)
+	}
+
+	return nil
+}
+
+func handlerTag(s *state, cmd command, user database.User) error {
+	feed_follow, err := s.db.GetFeedFollowByUrl(
+		context.Background(),
+		database.GetFeedFollowByUrlParams{
+			UserID: user.ID,
+			Url:    cmd.args[0],
+		},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("you are not following %s", cmd.args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, name := range cmd.args[1:] {
+		if err := tagFeedFollow(s, user, feed_follow.ID, name); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Tagged %s with %s\n", cmd.args[0], strings.Join(cmd.args[1:], ", "))
+
+	return nil
+}
+
+func handlerUntag(s *state, cmd command, user database.User) error {
+	feed_follow, err := s.db.GetFeedFollowByUrl(
+		context.Background(),
+		database.GetFeedFollowByUrlParams{
+			UserID: user.ID,
+			Url:    cmd.args[0],
+		},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("you are not following %s", cmd.args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, name := range cmd.args[1:] {
+		count, err := s.db.UntagFeedFollow(
+			context.Background(),
+			database.UntagFeedFollowParams{
+				FeedFollowID: feed_follow.ID,
+				UserID:       user.ID,
+				Name:         strings.TrimSpace(name),
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			fmt.Printf("%s is not tagged %s\n", cmd.args[0], name)
+		} else {
+			fmt.Printf("Removed tag %s from %s\n", name, cmd.args[0])
+		}
+	}
+
+	return s.db.DeleteUnusedTags(context.Background(), user.ID)
+}
+
+func handlerTags(s *state, cmd command, user database.User) error {
+	tags, err := s.db.GetTagsForUser(context.Background(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]tagRow, len(tags))
+	for i, tag := range tags {
+		rows[i] = tagRow{Name: tag.Name, FeedCount: tag.FeedCount}
+	}
+
+	return printListing(cmd, rows, func() error {
+		if len(rows) == 0 {
+			fmt.Println("You have no tags")
+			return nil
+		}
+
+		for _, row := range rows {
+			fmt.Printf("* %s (%d feed(s))\n", row.Name, row.FeedCount)
+		}
+		return nil
+	})
+}
+
+// tagFeedFollow adds the named tag to a feed follow, creating the tag for the
+// user if it doesn't exist yet. Blank names are ignored.
+func tagFeedFollow(s *state, user database.User, feedFollowID uuid.UUID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+
+	tag, err := s.db.UpsertTag(
+		context.Background(),
+		database.UpsertTagParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID:    user.ID,
+			Name:      name,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.db.TagFeedFollow(
+		context.Background(),
+		database.TagFeedFollowParams{
+			FeedFollowID: feedFollowID,
+			TagID:        tag.ID,
+		},
+	)
+}
+
+type browseOptions struct {
+	unread   bool
+	read     bool
+	markRead bool
+	feedURL  string
+	tag      string
+	since    string
+	until    string
+	sortBy   string
+	cursor   string
+}
+
+func handlerBrowse(s *state, cmd command, user database.User, opts browseOptions) error {
+	limit := int32(2)
+	if len(cmd.args) > 0 {
+		inputLimit, err := strconv.ParseInt(cmd.args[0], 10, 32)
+		if err != nil || inputLimit < 1 {
+			return usageErrorf("invalid limit %q, expected a number of posts of at least 1", cmd.args[0])
+		}
+		limit = int32(inputLimit)
+	}
+
+	if opts.sortBy != "fetched" && opts.sortBy != "published" {
+		return fmt.Errorf("invalid sort %q, expected fetched or published", opts.sortBy)
+	}
+
+	if opts.unread && opts.read {
+		return fmt.Errorf("--unread and --read can't be used together")
+	}
+
+	params := database.GetPostsForUserParams{
+		SortBy:   opts.sortBy,
+		UserID:   user.ID,
+		FeedUrl:  nullString(opts.feedURL),
+		Tag:      nullString(opts.tag),
+		MaxPosts: limit,
+	}
+
+	if opts.unread || opts.read {
+		params.IsRead = sql.NullBool{Bool: opts.read, Valid: true}
+	}
+
+	var err error
+	if params.Since, err = parseDateFlag(opts.since); err != nil {
+		return err
+	}
+	if params.Until, err = parseDateFlag(opts.until); err != nil {
+		return err
+	}
+
+	if opts.cursor != "" {
+		cursorAt, cursorID, err := decodePostCursor(opts.cursor)
+		if err != nil {
+			return err
+		}
+		params.CursorAt = sql.NullTime{Time: cursorAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursorID, Valid: true}
+	}
+
+	posts, err := s.db.GetPostsForUser(context.Background(), params)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]postRow, len(posts))
+	for i, row := range posts {
+		rows[i] = newPostRow(row.Post, row.FeedName)
+
+		sortAt := row.Post.CreatedAt
+		if opts.sortBy == "published" && row.Post.PublishedAt.Valid {
+			sortAt = row.Post.PublishedAt.Time
+		}
+		rows[i].Cursor = encodePostCursor(sortAt, row.Post.ID)
+
+		if opts.markRead {
+			err := s.db.MarkPostRead(
+				context.Background(),
+				database.MarkPostReadParams{
+					UserID: user.ID,
+					PostID: row.Post.ID,
+					ReadAt: time.Now(),
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return printListing(cmd, rows, func() error {
+		if len(rows) == 0 {
+			fmt.Println("No posts to show")
+			return nil
+		}
+
+		printPostList(os.Stdout, stdoutTerminal(), rows)
+
+		if len(rows) == int(limit) {
+			fmt.Printf("\nNext page: --cursor %s\n", rows[len(rows)-1].Cursor)
+		}
+		return nil
+	})
+}
+
+// encodePostCursor builds the opaque keyset pagination cursor for the post
+// with the given sort time and ID.
+func encodePostCursor(sortAt time.Time, id uuid.UUID) string {
+	raw := fmt.Sprintf("%d:%s", sortAt.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePostCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	postID, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	return time.Unix(0, unixNano), postID, nil
+}
+
+func parseDateFlag(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return sql.NullTime{Time: t, Valid: true}, nil
+		}
+	}
+
+	return sql.NullTime{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+}
+
+type postsOptions struct {
+	limit int
+}
+
+func handlerPosts(s *state, cmd command, opts postsOptions) error {
+	if opts.limit < 1 {
+		return usageErrorf("invalid limit %d, expected a number of posts of at least 1", opts.limit)
+	}
+
+	posts, err := s.db.GetPostsForFeed(
+		context.Background(),
+		database.GetPostsForFeedParams{
+			Url:   cmd.args[0],
+			Limit: int32(opts.limit),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]postRow, len(posts))
+	for i, row := range posts {
+		rows[i] = newPostRow(row.Post, row.FeedName)
+	}
+
+	return printListing(cmd, rows, func() error {
+		if len(rows) == 0 {
+			fmt.Println("No posts to show")
+			return nil
+		}
+
+		printPostList(os.Stdout, stdoutTerminal(), rows)
+		return nil
+	})
+}
+
+type searchOptions struct {
+	limit int
+}
+
+func handlerSearch(s *state, cmd command, user database.User, opts searchOptions) error {
+	if opts.limit < 1 {
+		return usageErrorf("invalid limit %d, expected a number of posts of at least 1", opts.limit)
+	}
+
+	results, err := s.db.SearchPostsForUser(
+		context.Background(),
+		database.SearchPostsForUserParams{
+			Query:    strings.Join(cmd.args, " "),
+			UserID:   user.ID,
+			MaxPosts: int32(opts.limit),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]searchResultRow, len(results))
+	for i, result := range results {
+		rows[i] = searchResultRow{
+			ID:          result.ID,
+			Title:       result.Title,
+			Feed:        result.FeedName,
+			URL:         result.Url,
+			Snippet:     strings.TrimSpace(result.Snippet),
+			PublishedAt: nullTimePtr(result.PublishedAt),
+		}
+	}
+
+	return printListing(cmd, rows, func() error {
+		if len(rows) == 0 {
+			fmt.Println("No posts matched your search")
+			return nil
+		}
+
+		posts := make([]postRow, len(rows))
+		for i, row := range rows {
+			posts[i] = postRow{
+				ID:          row.ID,
+				Title:       row.Title,
+				Feed:        row.Feed,
+				URL:         row.URL,
+				Description: &row.Snippet,
+				PublishedAt: row.PublishedAt,
+			}
+		}
+		printPostList(os.Stdout, stdoutTerminal(), posts)
+		return nil
+	})
+}
+
+func handlerRead(s *state, cmd command, user database.User) error {
+	post, err := resolvePost(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	err = s.db.MarkPostRead(
+		context.Background(),
+		database.MarkPostReadParams{
+			UserID: user.ID,
+			PostID: post.ID,
+			ReadAt: time.Now(),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Marked %s as read\n", post.Title)
+
+	return nil
+}
+
+func handlerMarkAllRead(s *state, cmd command, user database.User) error {
+	var count int64
+	var err error
+	if len(cmd.args) > 0 {
+		count, err = s.db.MarkFeedPostsRead(
+			context.Background(),
+			database.MarkFeedPostsReadParams{
+				UserID: user.ID,
+				ReadAt: time.Now(),
+				Url:    cmd.args[0],
+			},
+		)
+	} else {
+		count, err = s.db.MarkAllPostsRead(
+			context.Background(),
+			database.MarkAllPostsReadParams{
+				UserID: user.ID,
+				ReadAt: time.Now(),
+			},
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Marked %d post(s) as read\n", count)
+
+	return nil
+}
+
+func handlerStar(s *state, cmd command, user database.User) error {
+	post, err := resolvePost(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	err = s.db.StarPost(
+		context.Background(),
+		database.StarPostParams{
+			UserID:  user.ID,
+			PostID:  post.ID,
+			SavedAt: time.Now(),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Starred %s\n", post.Title)
+
+	return nil
+}
+
+func handlerUnstar(s *state, cmd command, user database.User) error {
+	post, err := resolvePost(s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	count, err := s.db.UnstarPost(
+		context.Background(),
+		database.UnstarPostParams{
+			UserID: user.ID,
+			PostID: post.ID,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("%s is not starred\n", post.Title)
+		return nil
+	}
+
+	fmt.Printf("Unstarred %s\n", post.Title)
+
+	return nil
+}
+
+func handlerStarred(s *state, cmd command, user database.User) error {
+	posts, err := s.db.GetStarredPostsForUser(
+		context.Background(),
+		user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]postRow, len(posts))
+	for i, row := range posts {
+		rows[i] = newPostRow(row.Post, row.FeedName)
+	}
+
+	return printListing(cmd, rows, func() error {
+		if len(rows) == 0 {
+			fmt.Println("You have no starred posts")
+			return nil
+		}
+
+		printPostList(os.Stdout, stdoutTerminal(), rows)
+		return nil
+	})
+}
+
+// shortPostIDLength is the number of leading characters of a post's UUID
+// printed by listing commands and accepted by post-level commands.
+const shortPostIDLength = 8
+
+func shortPostID(id uuid.UUID) string {
+	return id.String()[:shortPostIDLength]
+}
+
+// resolvePost finds the post a user refers to on the command line, either by
+// its URL or by a prefix of its ID among the posts the user can see.
+func resolvePost(s *state, user database.User, ref string) (database.Post, error) {
+	if strings.Contains(ref, "://") {
+		post, err := s.db.GetPostByUrl(context.Background(), ref)
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Post{}, fmt.Errorf("no post with URL %s", ref)
+		}
+		return post, err
+	}
+
+	prefix := strings.ToLower(ref)
+	if len(prefix) < 4 || strings.Trim(prefix, "0123456789abcdef-") != "" {
+		return database.Post{}, fmt.Errorf("invalid post ID %q", ref)
+	}
+
+	posts, err := s.db.GetPostsByIDPrefix(
+		context.Background(),
+		database.GetPostsByIDPrefixParams{
+			Prefix: prefix,
+			UserID: user.ID,
+		},
+	)
+	if err != nil {
+		return database.Post{}, err
+	}
+
+	switch len(posts) {
+	case 0:
+		return database.Post{}, fmt.Errorf("no post found with ID %q", ref)
+	case 1:
+		return posts[0], nil
+	default:
+		return database.Post{}, fmt.Errorf("post ID %q is ambiguous, use more characters", ref)
+	}
+}
+
+func handlerImport(s *state, cmd command, user database.User) error {
+	doc, err := readOPML(cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	imported := 0
+	createdFeeds := 0
+	duplicates := 0
+	invalid := 0
+	seen := make(map[string]uuid.UUID)
+
+	// What happened to each entry is only printed once the import commits,
+	// since none of it happens if a later entry fails.
+	var report []string
+
+	// The whole file is imported in one transaction, so a failure halfway
+	// doesn't leave some of the feeds imported.
+	err = withTx(s, func(tx *state) error {
+		for _, entry := range doc.feeds() {
+			if err := validateFeedURL(entry.URL); err != nil {
+				report = append(report, fmt.Sprintf("Invalid: %q (%s): %v", entry.Name, entry.URL, err))
+				invalid++
+				continue
+			}
+
+			if followID, ok := seen[entry.URL]; ok {
+				report = append(report, fmt.Sprintf("Duplicate in file: %s", entry.URL))
+				duplicates++
+				if err := tagFeedFollow(tx, user, followID, entry.Folder); err != nil {
+					return err
+				}
+				continue
+			}
+
+			feed, err := tx.db.GetFeedByUrl(context.Background(), entry.URL)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+
+			if errors.Is(err, sql.ErrNoRows) {
+				name := entry.Name
+				if name == "" {
+					name = entry.URL
+				}
+
+				feed, err = tx.db.CreateFeed(
+					context.Background(),
+					database.CreateFeedParams{
+						ID:        uuid.New(),
+						Name:      name,
+						Url:       entry.URL,
+						UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+						CreatedAt: time.Now(),
+						UpdatedAt: time.Now(),
+					},
+				)
+				if err != nil {
+					return err
+				}
+				createdFeeds++
+			} else {
+				feed_follow, err := tx.db.GetFeedFollow(
+					context.Background(),
+					database.GetFeedFollowParams{
+						UserID: user.ID,
+						FeedID: feed.ID,
+					},
+				)
+				if err == nil {
+					report = append(report, fmt.Sprintf("Already following: %s", entry.URL))
+					duplicates++
+					seen[entry.URL] = feed_follow.ID
+					if err := tagFeedFollow(tx, user, feed_follow.ID, entry.Folder); err != nil {
+						return err
+					}
+					continue
+				}
+				if !errors.Is(err, sql.ErrNoRows) {
+					return err
+				}
+			}
+
+			feed_follow, err := tx.db.CreateFeedFollow(
+				context.Background(),
+				database.CreateFeedFollowParams{
+					ID:        uuid.New(),
+					FeedID:    feed.ID,
+					UserID:    user.ID,
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+				},
+			)
+			if err != nil {
+				return err
+			}
+			seen[entry.URL] = feed_follow.ID
+
+			if err := tagFeedFollow(tx, user, feed_follow.ID, entry.Folder); err != nil {
+				return err
+			}
+
+			imported++
+			if entry.Folder != "" {
+				report = append(report, fmt.Sprintf("Followed: %s [%s]", feed.Name, entry.Folder))
+			} else {
+				report = append(report, fmt.Sprintf("Followed: %s", feed.Name))
+			}
+		}
+
+		return updateOrphanedFeeds(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("nothing was imported: %w", err)
+	}
+
+	for _, line := range report {
+		fmt.Println(line)
+	}
+
+	fmt.Printf(
+		"\nImported %d feed(s) (%d new), %d duplicate(s), %d invalid\n",
+		imported,
+		createdFeeds,
+		duplicates,
+		invalid,
+	)
+
+	return nil
+}
+
+func handlerExport(s *state, cmd command, user database.User) error {
+	feed_follows, err := s.db.GetFeedFollowsForUser(
+		context.Background(),
+		database.GetFeedFollowsForUserParams{
+			UserID: user.ID,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Feeds are nested under a folder for each of their tags, the way most
+	// readers export feeds that belong to several categories.
+	var feeds []OPMLFeed
+	for _, feed_follow := range feed_follows {
+		feed := OPMLFeed{
+			Name:    feed_follow.FeedName,
+			URL:     feed_follow.FeedUrl,
+			SiteURL: feed_follow.FeedSiteLink.String,
+		}
+
+		if len(feed_follow.Tags) == 0 {
+			feeds = append(feeds, feed)
+			continue
+		}
+
+		for _, tag := range feed_follow.Tags {
+			feed.Folder = tag
+			feeds = append(feeds, feed)
+		}
+	}
+
+	doc := newOPML(fmt.Sprintf("%s's subscriptions in gator", user.Name), feeds)
+	doc.Head.OwnerName = user.Name
+
+	if len(cmd.args) == 0 || cmd.args[0] == "-" {
+		return doc.write(os.Stdout)
+	}
+
+	file, err := os.Create(cmd.args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := doc.write(file); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d feed(s) to %s\n", len(feed_follows), cmd.args[0])
+
 	return nil
 }
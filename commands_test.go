@@ -0,0 +1,1247 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thihxm/gator/internal/config"
+	"github.com/thihxm/gator/internal/database"
+	"github.com/thihxm/gator/internal/memory"
+)
+
+// newTestFeeds registers alice, has her add a Go and a Rust feed with two
+// posts each, and fetches them.
+func newTestFeeds(t *testing.T) (*state, *feedServer, string, string) {
+	t.Helper()
+
+	s := newTestState(t)
+	server := newFeedServer(t)
+	now := time.Now()
+
+	goURL := server.setFeed("/go", "Go Blog",
+		testItem{
+			title:       "Generics in Go",
+			link:        "https://example.com/go/generics",
+			description: "Type parameters explained",
+			content:     "Write generic functions with type parameters",
+			published:   now.Add(-4 * time.Hour),
+		},
+		testItem{
+			title:       "Go modules",
+			link:        "https://example.com/go/modules",
+			description: "Managing dependencies",
+			published:   now.Add(-3 * time.Hour),
+		},
+	)
+	rustURL := server.setFeed("/rust", "Rust Blog",
+		testItem{
+			title:       "Ownership",
+			link:        "https://example.com/rust/ownership",
+			description: "Borrowing and lifetimes",
+			published:   now.Add(-2 * time.Hour),
+		},
+		testItem{
+			title:       "Async Rust",
+			link:        "https://example.com/rust/async",
+			description: "Futures and executors",
+			published:   now.Add(-time.Hour),
+		},
+	)
+
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", goURL)
+	mustRun(t, s, "addfeed", rustURL)
+	fetchAll(t, s)
+
+	return s, server, goURL, rustURL
+}
+
+// postID returns the short ID printed for the post with the given URL.
+func postID(t *testing.T, s *state, url string) string {
+	t.Helper()
+
+	post, err := s.db.GetPostByUrl(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return shortPostID(post.ID)
+}
+
+func TestHandlerRegisterAndLogin(t *testing.T) {
+	s := newTestState(t)
+
+	_, err := run(t, s, "register")
+	assertError(t, err, "usage: gator register [flags] <username>")
+
+	out := mustRun(t, s, "register", "alice")
+	assertContains(t, out, "User registered successfully", "Logged in as alice")
+
+	out = mustRun(t, s, "register", "bob", "--output", "json")
+	var registered userRow
+	decodeJSON(t, out, &registered)
+	if registered.Name != "bob" || !registered.Current || registered.CreatedAt.IsZero() {
+		t.Errorf("unexpected registered user: %+v", registered)
+	}
+
+	_, err = run(t, s, "register", "alice")
+	assertError(t, err, "a user with that name already exists")
+
+	if *s.cfg.CurrentUserName != "bob" {
+		t.Errorf("expected bob to be logged in, got %s", *s.cfg.CurrentUserName)
+	}
+
+	out = mustRun(t, s, "login", "alice")
+	assertContains(t, out, "Logged in as alice")
+
+	saved, err := config.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.CurrentUserName == nil || *saved.CurrentUserName != "alice" {
+		t.Errorf("the config file wasn't updated: %+v", saved)
+	}
+
+	_, err = run(t, s, "login")
+	assertError(t, err, "usage: gator login <username>")
+
+	_, err = run(t, s, "login", "carol")
+	assertError(t, err, "no user named carol")
+}
+
+func TestHandlerUsers(t *testing.T) {
+	s := newTestState(t)
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "register", "bob")
+
+	out := mustRun(t, s, "users")
+	assertContains(t, out, "* alice\n", "* bob (current)\n")
+}
+
+func TestMiddlewareLoggedIn(t *testing.T) {
+	s := newTestState(t)
+
+	_, err := run(t, s, "following")
+	assertError(t, err, "you need to login first")
+
+	name := "ghost"
+	s.cfg.CurrentUserName = &name
+	if _, err := run(t, s, "following"); err == nil {
+		t.Error("expected an error for a logged in user that doesn't exist")
+	}
+}
+
+func TestHandlerReset(t *testing.T) {
+	s, _, _, _ := newTestFeeds(t)
+
+	mustRun(t, s, "reset")
+
+	users, _ := s.db.GetUsers(context.Background())
+	feeds, _ := s.db.GetFeeds(context.Background())
+	if len(users) != 0 || len(feeds) != 0 {
+		t.Errorf("expected no users and feeds, got %d and %d", len(users), len(feeds))
+	}
+}
+
+func TestHandlerUserDelete(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+	mustRun(t, s, "register", "bob")
+	mustRun(t, s, "follow", goURL)
+
+	_, err := run(t, s, "user")
+	assertError(t, err, "usage: gator user <command>")
+	_, err = run(t, s, "user", "delete")
+	assertError(t, err, "usage: gator user delete <username>")
+	_, err = run(t, s, "user", "delete", "carol")
+	assertError(t, err, "no user named carol")
+
+	out := mustRun(t, s, "user", "delete", "alice")
+	assertContains(t, out, "Deleted user alice")
+
+	out = mustRun(t, s, "feeds")
+	assertRow(t, out, "Go Blog", goURL, "bob")
+	assertRow(t, out, "Rust Blog", rustURL, "(deleted user)")
+
+	mustRun(t, s, "user", "delete", "bob")
+	if s.cfg.CurrentUserName != nil {
+		t.Error("expected the current user to be cleared after deleting them")
+	}
+}
+
+func TestHandlerAgg(t *testing.T) {
+	s := newTestState(t)
+
+	_, err := run(t, s, "agg")
+	assertError(t, err, "usage: gator agg <interval>")
+
+	if _, err := run(t, s, "agg", "soon"); err == nil {
+		t.Error("expected an error for an invalid time period")
+	}
+}
+
+func TestHandlerAddFeed(t *testing.T) {
+	s := newTestState(t)
+	server := newFeedServer(t)
+	url := server.setFeed("/go", "Go Blog")
+
+	_, err := run(t, s, "addfeed", url)
+	assertError(t, err, "you need to login first")
+
+	mustRun(t, s, "register", "alice")
+
+	_, err = run(t, s, "addfeed")
+	assertError(t, err, "usage: gator addfeed [flags] [name] <url>")
+
+	out := mustRun(t, s, "addfeed", url)
+	assertContains(t, out, "Go Blog")
+
+	out = mustRun(t, s, "addfeed", "Custom name", server.URL+"/other")
+	assertContains(t, out, "Custom name")
+
+	out = mustRun(t, s, "following")
+	assertRow(t, out, "Custom name", server.URL+"/other")
+	assertRow(t, out, "Go Blog", url)
+
+	if _, err := run(t, s, "addfeed", server.URL+"/missing"); err == nil {
+		t.Error("expected an error adding a feed that can't be fetched")
+	}
+}
+
+func TestHandlerAddFeedExisting(t *testing.T) {
+	s, server, goURL, _ := newTestFeeds(t)
+	mustRun(t, s, "register", "bob")
+
+	// The feed isn't fetched again, so it can be added while it's down.
+	server.mu.Lock()
+	delete(server.feeds, "/go")
+	server.mu.Unlock()
+
+	out := mustRun(t, s, "addfeed", "Another name", goURL)
+	assertContains(t, out,
+		"The feed "+goURL+" has already been added as Go Blog",
+		"User bob followed the feed Go Blog successfully!",
+	)
+
+	out = mustRun(t, s, "addfeed", goURL)
+	assertContains(t, out, "User bob is already following the feed Go Blog")
+
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feeds) != 2 {
+		t.Errorf("expected no feed to be added, got %d feeds", len(feeds))
+	}
+}
+
+func TestHandlerFeeds(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+
+	out := mustRun(t, s, "feeds")
+	assertRow(t, out, "NAME", "URL", "ADDED BY", "LAST FETCHED")
+	assertRow(t, out, "Go Blog", goURL, "alice", time.Now().Format("2006-01-02"))
+	assertRow(t, out, "Rust Blog", rustURL, "alice")
+}
+
+func TestHandlerFeed(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+
+	_, err := run(t, s, "feed")
+	assertError(t, err, "usage: gator feed")
+	_, err = run(t, s, "feed", "explode", goURL)
+	assertError(t, err, `unknown feed command "explode"`)
+}
+
+func TestHandlerFeedRename(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+
+	_, err := run(t, s, "feed", "rename", goURL)
+	assertError(t, err, "usage: gator feed rename <url> <name>")
+
+	out := mustRun(t, s, "feed", "rename", goURL, "The", "Go", "Blog")
+	assertContains(t, out, "Renamed "+goURL+" to The Go Blog")
+
+	_, err = run(t, s, "feed", "rename", "https://example.com/unknown", "Mine")
+	assertError(t, err, "no feed with URL https://example.com/unknown")
+
+	mustRun(t, s, "register", "bob")
+	_, err = run(t, s, "feed", "rename", goURL, "Mine")
+	assertError(t, err, "only the user who added")
+}
+
+func TestHandlerFeedSetUrl(t *testing.T) {
+	s, server, goURL, rustURL := newTestFeeds(t)
+	newURL := server.setFeed("/go-new", "Go Blog")
+
+	_, err := run(t, s, "feed", "set-url", goURL, "ftp://example.com")
+	assertError(t, err, "invalid feed URL")
+
+	_, err = run(t, s, "feed", "set-url", goURL, rustURL)
+	assertError(t, err, "a feed with that URL already exists")
+
+	out := mustRun(t, s, "feed", "set-url", goURL, newURL)
+	assertContains(t, out, "Go Blog now points to "+newURL)
+
+	feed, err := s.db.GetFeedByUrl(context.Background(), newURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.LastFetchedAt.Valid {
+		t.Error("expected the feed to be fetched again after changing its URL")
+	}
+}
+
+func TestHandlerFeedRemove(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+	mustRun(t, s, "register", "bob")
+	mustRun(t, s, "follow", goURL)
+	mustRun(t, s, "login", "alice")
+
+	_, err := run(t, s, "feed", "rm")
+	assertError(t, err, "usage: gator feed rm [flags] <url>")
+
+	out := mustRun(t, s, "feed", "rm", goURL)
+	assertContains(t, out, "handed over instead of deleted")
+
+	out = mustRun(t, s, "feeds")
+	assertRow(t, out, "Go Blog", goURL, "bob")
+
+	out = mustRun(t, s, "following")
+	assertNotContains(t, out, "Go Blog")
+
+	out = mustRun(t, s, "feed", "rm", rustURL)
+	assertContains(t, out, "Deleted Rust Blog and its posts")
+
+	mustRun(t, s, "login", "bob")
+	out = mustRun(t, s, "feed", "rm", "--force", goURL)
+	assertContains(t, out, "Deleted Go Blog and its posts")
+
+	out = mustRun(t, s, "feeds")
+	assertContains(t, out, "No feeds have been added yet")
+}
+
+func TestHandlerFeedRetention(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+
+	_, err := run(t, s, "feed", "retention")
+	assertError(t, err, "usage: gator feed retention [flags] <url>")
+
+	out := mustRun(t, s, "feed", "retention", goURL)
+	assertContains(t, out, "Max age: global setting", "Max posts: global setting")
+
+	out = mustRun(t, s, "feed", "retention", "--max-age", "48h", "--max-posts", "10", goURL)
+	assertContains(t, out, "Max age: 48h0m0s", "Max posts: 10")
+
+	_, err = run(t, s, "feed", "retention", "--max-posts", "-1", goURL)
+	assertError(t, err, "can't be negative")
+
+	out = mustRun(t, s, "feed", "retention", "--max-age", "0", goURL)
+	assertContains(t, out, "Max age: forever", "Max posts: 10")
+
+	out = mustRun(t, s, "feed", "retention", "--global", "--max-posts", "0", goURL)
+	assertContains(t, out, "Max age: global setting", "Max posts: unlimited")
+
+	// Every follower has their own policy.
+	mustRun(t, s, "register", "bob")
+	_, err = run(t, s, "feed", "retention", goURL)
+	assertError(t, err, "you are not following "+goURL)
+
+	mustRun(t, s, "follow", goURL)
+	out = mustRun(t, s, "feed", "retention", goURL)
+	assertContains(t, out, "Max age: global setting", "Max posts: global setting")
+}
+
+func TestHandlerFollowAndUnfollow(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+	mustRun(t, s, "register", "bob")
+
+	_, err := run(t, s, "follow")
+	assertError(t, err, "usage: gator follow <url>")
+
+	out := mustRun(t, s, "follow", goURL)
+	assertContains(t, out, "User bob followed the feed Go Blog successfully!")
+
+	out = mustRun(t, s, "follow", goURL)
+	assertContains(t, out, "User bob is already following the feed Go Blog")
+
+	_, err = run(t, s, "follow", "https://example.com/unknown")
+	assertError(t, err, "gator addfeed https://example.com/unknown")
+
+	_, err = run(t, s, "unfollow")
+	assertError(t, err, "usage: gator unfollow <url>")
+
+	mustRun(t, s, "unfollow", goURL)
+	out = mustRun(t, s, "following")
+	assertContains(t, out, "You are not following any feeds")
+}
+
+func TestHandlerFollowing(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+	mustRun(t, s, "tag", goURL, "programming")
+
+	out := mustRun(t, s, "following")
+	assertRow(t, out, "Go Blog", goURL, "programming")
+	assertRow(t, out, "Rust Blog", rustURL)
+
+	out = mustRun(t, s, "following", "--tag", "programming")
+	assertRow(t, out, "Go Blog", goURL, "programming")
+	assertNotContains(t, out, "Rust Blog")
+
+	out = mustRun(t, s, "following", "--tag", "cooking")
+	assertContains(t, out, "You are not following any feeds tagged cooking")
+}
+
+func TestHandlerTitle(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+
+	_, err := run(t, s, "title")
+	assertError(t, err, "usage: gator title <url> [title]")
+
+	out := mustRun(t, s, "title", goURL, "Gopher", "news")
+	assertContains(t, out, "Renamed "+goURL+" to Gopher news for you")
+
+	out = mustRun(t, s, "following")
+	assertRow(t, out, "Gopher news", goURL)
+
+	out = mustRun(t, s, "browse", "--feed", goURL)
+	assertContains(t, out, "Gopher news · ")
+
+	out = mustRun(t, s, "feeds")
+	assertRow(t, out, "Go Blog", goURL)
+
+	out = mustRun(t, s, "title", goURL)
+	assertContains(t, out, "Reset the title of "+goURL)
+
+	_, err = run(t, s, "title", "https://example.com/unknown", "Nope")
+	assertError(t, err, "you are not following")
+}
+
+func TestHandlerTags(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+
+	out := mustRun(t, s, "tags")
+	assertContains(t, out, "You have no tags")
+
+	_, err := run(t, s, "tag", goURL)
+	assertError(t, err, "usage: gator tag <url> <tag>...")
+
+	out = mustRun(t, s, "tag", goURL, "programming", "news")
+	assertContains(t, out, "Tagged "+goURL+" with programming, news")
+	mustRun(t, s, "tag", rustURL, "programming")
+	mustRun(t, s, "tag", rustURL, "programming")
+
+	out = mustRun(t, s, "tags")
+	assertContains(t, out, "* news (1 feed(s))\n* programming (2 feed(s))\n")
+
+	_, err = run(t, s, "untag", goURL)
+	assertError(t, err, "usage: gator untag <url> <tag>...")
+
+	out = mustRun(t, s, "untag", goURL, "news", "cooking")
+	assertContains(t, out, "Removed tag news from "+goURL, goURL+" is not tagged cooking")
+
+	out = mustRun(t, s, "tags")
+	assertContains(t, out, "* programming (2 feed(s))")
+	assertNotContains(t, out, "news")
+
+	_, err = run(t, s, "tag", "https://example.com/unknown", "news")
+	assertError(t, err, "you are not following https://example.com/unknown")
+	_, err = run(t, s, "untag", "https://example.com/unknown", "news")
+	assertError(t, err, "you are not following https://example.com/unknown")
+}
+
+func TestHandlerBrowse(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+
+	out := mustRun(t, s, "browse", "10", "--sort", "published")
+	assertPostTitles(t, out, "Async Rust", "Ownership", "Go modules", "Generics in Go")
+	assertNotContains(t, out, "Next page")
+
+	out = mustRun(t, s, "browse", "--feed", goURL, "10")
+	assertContains(t, out, "Generics in Go", "Go modules")
+	assertNotContains(t, out, "Ownership")
+
+	_, err := run(t, s, "browse", "--sort", "random")
+	assertError(t, err, "invalid sort")
+
+	for _, limit := range []string{"0", "-5", "many"} {
+		_, err = run(t, s, "browse", "--", limit)
+		assertError(t, err, fmt.Sprintf("invalid limit %q, expected a number of posts of at least 1\nusage: gator browse [flags] [limit]", limit))
+	}
+
+	_, err = run(t, s, "browse", "--read", "--unread")
+	assertError(t, err, "can't be used together")
+
+	_, err = run(t, s, "browse", "--since", "yesterday")
+	assertError(t, err, "invalid date")
+
+	out = mustRun(t, s, "browse", "--since", time.Now().Add(time.Hour).Format(time.RFC3339))
+	assertContains(t, out, "No posts to show")
+}
+
+func TestHandlerBrowsePagination(t *testing.T) {
+	s, _, _, _ := newTestFeeds(t)
+
+	seen := make(map[string]bool)
+	args := []string{"--sort", "published", "3"}
+	for page := 0; page < 3; page++ {
+		out := mustRun(t, s, "browse", args...)
+
+		for _, title := range postTitles(out) {
+			if seen[title] {
+				t.Fatalf("post %q shown twice", title)
+			}
+			seen[title] = true
+		}
+
+		_, cursor, ok := strings.Cut(out, "Next page: --cursor ")
+		if !ok {
+			break
+		}
+		args = []string{"--sort", "published", "--cursor", strings.TrimSpace(cursor), "3"}
+	}
+
+	if len(seen) != 4 {
+		t.Errorf("expected to page through 4 posts, saw %d", len(seen))
+	}
+
+	_, err := run(t, s, "browse", "--cursor", "nope")
+	assertError(t, err, "invalid cursor")
+}
+
+func TestSQLiteBrowsePagination(t *testing.T) {
+	s := newSQLiteTestState(t)
+	server := newFeedServer(t)
+	url := server.setFeed("/db", "Databases")
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", url)
+
+	feed, err := s.db.GetFeedByUrl(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SQLite keeps nanoseconds, so posts fetched within the same microsecond
+	// are still told apart by the cursor.
+	fetchedAt := time.Now().Truncate(time.Microsecond)
+	for i, title := range []string{"First", "Second", "Third"} {
+		_, err := s.db.CreatePost(context.Background(), database.CreatePostParams{
+			ID:        uuid.New(),
+			CreatedAt: fetchedAt.Add(time.Duration(i+1) * 100 * time.Nanosecond),
+			UpdatedAt: fetchedAt,
+			Title:     title,
+			Url:       "https://example.com/db/" + title,
+			FeedID:    feed.ID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var titles []string
+	args := []string{"1"}
+	for page := 0; page < 4; page++ {
+		var posts []postRow
+		decodeJSON(t, mustRun(t, s, "browse", append(args, "--output", "json")...), &posts)
+		if len(posts) == 0 {
+			break
+		}
+		titles = append(titles, posts[0].Title)
+		args = []string{"--cursor", posts[0].Cursor, "1"}
+	}
+
+	if !slices.Equal(titles, []string{"Third", "Second", "First"}) {
+		t.Errorf("expected to page through every post, got %q", titles)
+	}
+}
+
+func TestHandlerBrowseReadFilters(t *testing.T) {
+	s, _, _, _ := newTestFeeds(t)
+
+	out := mustRun(t, s, "browse", "--sort", "published", "--mark-read", "1")
+	assertPostTitles(t, out, "Async Rust")
+
+	out = mustRun(t, s, "browse", "--read", "10")
+	assertContains(t, out, "Async Rust")
+	assertNotContains(t, out, "Ownership")
+
+	out = mustRun(t, s, "browse", "--unread", "10")
+	assertContains(t, out, "Ownership", "Go modules", "Generics in Go")
+	assertNotContains(t, out, "Async Rust")
+}
+
+func TestHandlerBrowseTag(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+	mustRun(t, s, "tag", goURL, "go")
+
+	out := mustRun(t, s, "browse", "--tag", "go", "10")
+	assertContains(t, out, "Generics in Go", "Go modules")
+	assertNotContains(t, out, "Rust")
+}
+
+func TestHandlerPosts(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+
+	_, err := run(t, s, "posts")
+	assertError(t, err, "usage: gator posts [flags] <feed-url>")
+
+	out := mustRun(t, s, "posts", "--limit", "1", goURL)
+	assertPostTitles(t, out, "Go modules")
+	assertContains(t, out, "Go Blog · ")
+
+	out = mustRun(t, s, "posts", "https://example.com/unknown")
+	assertContains(t, out, "No posts to show")
+
+	_, err = run(t, s, "posts", "--limit", "0", goURL)
+	assertError(t, err, "invalid limit 0, expected a number of posts of at least 1\nusage: gator posts [flags] <feed-url>")
+}
+
+func TestHandlerSearch(t *testing.T) {
+	s, _, _, _ := newTestFeeds(t)
+
+	_, err := run(t, s, "search")
+	assertError(t, err, "usage: gator search [flags] <query>")
+
+	out := mustRun(t, s, "search", "type", "parameters")
+	assertPostTitles(t, out, "Generics in Go")
+	assertContains(t, out, "Go Blog · ", "**parameters**")
+	assertNotContains(t, out, "Rust")
+
+	out = mustRun(t, s, "search", "haskell")
+	assertContains(t, out, "No posts matched your search")
+
+	_, err = run(t, s, "search", "--limit", "-1", "go")
+	assertError(t, err, "invalid limit -1, expected a number of posts of at least 1\nusage: gator search [flags] <query>")
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	s := newSQLiteTestState(t)
+	server := newFeedServer(t)
+	now := time.Now()
+
+	url := server.setFeed("/db", "Databases",
+		testItem{
+			title:       "Indexes in pgvector's new release",
+			link:        "https://example.com/db/pgvector",
+			description: "Faster nearest neighbour search",
+			published:   now.Add(-3 * time.Hour),
+		},
+		testItem{
+			title:       "Embedding SQLite in C++",
+			link:        "https://example.com/db/cpp",
+			description: "Linking the amalgamation",
+			published:   now.Add(-2 * time.Hour),
+		},
+		testItem{
+			title:       "Post one",
+			link:        "https://example.com/db/one",
+			description: "The first post",
+			published:   now.Add(-time.Hour),
+		},
+		testItem{
+			title:       "Post two",
+			link:        "https://example.com/db/two",
+			description: "The second post",
+			published:   now,
+		},
+	)
+
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", url)
+	fetchAll(t, s)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"pgvector's", []string{"Indexes in pgvector's new release"}},
+		{"c++", []string{"Embedding SQLite in C++"}},
+		{"post -two", []string{"Post one"}},
+		{`-"post two" post`, []string{"Post one"}},
+		{`"second post"`, []string{"Post two"}},
+		{"pgvector OR amalgamation", []string{"Indexes in pgvector's new release", "Embedding SQLite in C++"}},
+		{`title:post "unterminated`, nil},
+		{"-post", nil},
+		{"+ - *", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, s, "search", "--limit", "10", "--output", "json", "--", tt.query)
+		if err != nil {
+			t.Errorf("search %q: %v", tt.query, err)
+			continue
+		}
+
+		var results []searchResultRow
+		if err := json.Unmarshal([]byte(out), &results); err != nil {
+			t.Fatalf("search %q: %v\n%s", tt.query, err, out)
+		}
+
+		var titles []string
+		for _, result := range results {
+			titles = append(titles, result.Title)
+		}
+		slices.Sort(titles)
+		slices.Sort(tt.want)
+		if !slices.Equal(titles, tt.want) {
+			t.Errorf("search %q = %q, want %q", tt.query, titles, tt.want)
+		}
+	}
+}
+
+func TestHandlerRead(t *testing.T) {
+	s, _, _, _ := newTestFeeds(t)
+
+	_, err := run(t, s, "read")
+	assertError(t, err, "usage: gator read <post>")
+
+	out := mustRun(t, s, "read", postID(t, s, "https://example.com/go/modules"))
+	assertContains(t, out, "Marked Go modules as read")
+
+	out = mustRun(t, s, "read", "https://example.com/rust/async")
+	assertContains(t, out, "Marked Async Rust as read")
+
+	out = mustRun(t, s, "browse", "--read", "10")
+	assertContains(t, out, "Go modules", "Async Rust")
+	assertNotContains(t, out, "Ownership")
+
+	_, err = run(t, s, "read", "xyz")
+	assertError(t, err, "invalid post ID")
+
+	_, err = run(t, s, "read", "https://example.com/unknown")
+	assertError(t, err, "no post with URL https://example.com/unknown")
+}
+
+func TestHandlerMarkAllRead(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+
+	out := mustRun(t, s, "mark-all-read", goURL)
+	assertContains(t, out, "Marked 2 post(s) as read")
+
+	out = mustRun(t, s, "mark-all-read")
+	assertContains(t, out, "Marked 2 post(s) as read")
+
+	out = mustRun(t, s, "mark-all-read")
+	assertContains(t, out, "Marked 0 post(s) as read")
+
+	out = mustRun(t, s, "browse", "--unread")
+	assertContains(t, out, "No posts to show")
+}
+
+func TestHandlerStar(t *testing.T) {
+	s, _, _, _ := newTestFeeds(t)
+
+	out := mustRun(t, s, "starred")
+	assertContains(t, out, "You have no starred posts")
+
+	_, err := run(t, s, "star")
+	assertError(t, err, "usage: gator star <post>")
+
+	out = mustRun(t, s, "star", postID(t, s, "https://example.com/go/generics"))
+	assertContains(t, out, "Starred Generics in Go")
+	mustRun(t, s, "star", "https://example.com/rust/ownership")
+
+	out = mustRun(t, s, "starred")
+	if strings.Index(out, "Ownership") > strings.Index(out, "Generics in Go") {
+		t.Errorf("expected the most recently starred post first:\n%s", out)
+	}
+
+	_, err = run(t, s, "unstar")
+	assertError(t, err, "usage: gator unstar <post>")
+
+	out = mustRun(t, s, "unstar", "https://example.com/rust/ownership")
+	assertContains(t, out, "Unstarred Ownership")
+
+	out = mustRun(t, s, "unstar", "https://example.com/rust/ownership")
+	assertContains(t, out, "Ownership is not starred")
+
+	out = mustRun(t, s, "starred")
+	assertContains(t, out, "Generics in Go")
+	assertNotContains(t, out, "Ownership")
+}
+
+func TestHandlerPrune(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+	mustRun(t, s, "star", "https://example.com/go/generics")
+	mustRun(t, s, "feed", "retention", "--max-posts", "1", goURL)
+	s.cfg.RetentionMaxAge = "90m"
+
+	mustRun(t, s, "register", "bob")
+	mustRun(t, s, "follow", goURL)
+	mustRun(t, s, "feed", "retention", "--max-age", "0", goURL)
+
+	out := mustRun(t, s, "prune", "--dry-run")
+	assertContains(t, out, "* Rust Blog (", "1 post(s) would be deleted")
+	assertNotContains(t, out, "Go Blog")
+
+	mustRun(t, s, "feed", "retention", "--global", goURL)
+	mustRun(t, s, "login", "alice")
+
+	out = mustRun(t, s, "prune", "--dry-run")
+	assertContains(t, out,
+		"* Go Blog ("+goURL+"): 1 post(s)",
+		"* Rust Blog (",
+		"2 post(s) would be deleted",
+	)
+
+	out = mustRun(t, s, "prune")
+	assertContains(t, out, "Deleted 2 post(s)")
+
+	out = mustRun(t, s, "browse", "10")
+	assertContains(t, out, "Generics in Go", "Async Rust")
+	assertNotContains(t, out, "Go modules", "Ownership")
+
+	out = mustRun(t, s, "prune", "--dry-run")
+	assertContains(t, out, "No posts to delete")
+}
+
+func TestHandlerGC(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+	mustRun(t, s, "unfollow", goURL)
+
+	out := mustRun(t, s, "gc", "--dry-run")
+	assertContains(t, out, "No feeds to delete")
+
+	out = mustRun(t, s, "gc", "--grace", "0s", "--dry-run")
+	assertContains(t, out, "* Go Blog ("+goURL+")", "2 post(s)", "1 feed(s) would be deleted")
+
+	// Following the feed again keeps it, and unfollowing it restarts the
+	// grace period.
+	mustRun(t, s, "follow", goURL)
+	out = mustRun(t, s, "gc", "--grace", "0s", "--dry-run")
+	assertContains(t, out, "No feeds to delete")
+
+	mustRun(t, s, "unfollow", goURL)
+	out = mustRun(t, s, "gc", "--grace", "1h", "--dry-run")
+	assertContains(t, out, "No feeds to delete")
+
+	// A dry run only reads, so it works even if nothing can be written.
+	store := s.db.(*memory.Store)
+	s.db = &failingStore{Store: store, query: "MarkOrphanedFeeds"}
+	out = mustRun(t, s, "gc", "--grace", "0s", "--dry-run")
+	assertContains(t, out, "1 feed(s) would be deleted")
+	s.db = store
+
+	out = mustRun(t, s, "gc", "--grace", "0s")
+	assertContains(t, out, "Deleted 1 unfollowed feed(s)")
+
+	out = mustRun(t, s, "feeds")
+	assertContains(t, out, rustURL)
+	assertNotContains(t, out, goURL)
+
+	s.cfg.FeedGCGracePeriod = "forever"
+	_, err := run(t, s, "gc")
+	assertError(t, err, "invalid feed_gc_grace_period")
+}
+
+func TestHandlerGCKeepsStarredPosts(t *testing.T) {
+	for name, s := range map[string]*state{
+		"memory": newTestState(t),
+		"sqlite": newSQLiteTestState(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := newFeedServer(t)
+			goURL := server.setFeed("/go", "Go Blog",
+				testItem{title: "Generics in Go", link: "https://example.com/go/generics", published: time.Now()},
+			)
+			rustURL := server.setFeed("/rust", "Rust Blog",
+				testItem{title: "Ownership", link: "https://example.com/rust/ownership", published: time.Now()},
+			)
+
+			mustRun(t, s, "register", "alice")
+			mustRun(t, s, "addfeed", goURL)
+			mustRun(t, s, "addfeed", rustURL)
+			fetchAll(t, s)
+
+			mustRun(t, s, "star", "https://example.com/go/generics")
+			mustRun(t, s, "unfollow", goURL)
+			mustRun(t, s, "unfollow", rustURL)
+
+			out := mustRun(t, s, "gc", "--grace", "0s", "--dry-run")
+			assertContains(t, out, "* Rust Blog (", "1 feed(s) would be deleted")
+			assertNotContains(t, out, "Go Blog")
+
+			out = mustRun(t, s, "gc", "--grace", "0s")
+			assertContains(t, out, "Deleted 1 unfollowed feed(s)")
+
+			out = mustRun(t, s, "starred")
+			assertContains(t, out, "Generics in Go")
+
+			// Once the post is unstarred, the feed is deleted like any other.
+			mustRun(t, s, "unstar", "https://example.com/go/generics")
+			out = mustRun(t, s, "gc", "--grace", "0s")
+			assertContains(t, out, "Deleted 1 unfollowed feed(s)")
+		})
+	}
+}
+
+func TestHandlerImportAndExport(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+	mustRun(t, s, "tag", goURL, "dev/go")
+	mustRun(t, s, "title", rustURL, "Crabs")
+
+	_, err := run(t, s, "export")
+	assertError(t, err, "usage: gator export <command>")
+
+	path := filepath.Join(t.TempDir(), "feeds.opml")
+	out := mustRun(t, s, "export", "opml", path)
+	assertContains(t, out, "Exported 2 feed(s)")
+
+	exported, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(exported), `<outline text="dev"`, `<outline text="go"`, `text="Go Blog"`, `text="Crabs"`)
+
+	out = mustRun(t, s, "export", "opml")
+	assertContains(t, out, `xmlUrl="`+goURL+`"`)
+
+	_, err = run(t, s, "import", "json", path)
+	assertError(t, err, "unknown import command \"json\"")
+
+	mustRun(t, s, "register", "bob")
+	out = mustRun(t, s, "import", "opml", path)
+	assertContains(t, out, "Followed: Go Blog [dev/go]", "Imported 2 feed(s) (0 new), 0 duplicate(s), 0 invalid")
+
+	out = mustRun(t, s, "import", "opml", path)
+	assertContains(t, out, "Already following: "+goURL, "Imported 0 feed(s) (0 new), 2 duplicate(s), 0 invalid")
+
+	out = mustRun(t, s, "following")
+	assertRow(t, out, "Go Blog", goURL, "dev/go")
+	assertRow(t, out, "Rust Blog")
+}
+
+func TestHandlerImportNewFeeds(t *testing.T) {
+	s := newTestState(t)
+	mustRun(t, s, "register", "alice")
+
+	path := filepath.Join(t.TempDir(), "feeds.opml")
+	err := os.WriteFile(path, []byte(`<?xml version="1.0"?>
+<opml version="2.0">
+  <body>
+    <outline text="News">
+      <outline text="Example" xmlUrl="https://example.com/feed.xml"/>
+      <outline text="Example again" xmlUrl="https://example.com/feed.xml"/>
+    </outline>
+    <outline text="Broken" xmlUrl="mailto:someone@example.com"/>
+    <outline xmlUrl="https://example.org/rss"/>
+  </body>
+</opml>`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := mustRun(t, s, "import", "opml", path)
+	assertContains(t, out,
+		"Followed: Example [News]",
+		"Duplicate in file: https://example.com/feed.xml",
+		`Invalid: "Broken"`,
+		"Followed: https://example.org/rss",
+		"Imported 2 feed(s) (2 new), 1 duplicate(s), 1 invalid",
+	)
+
+	_, err = run(t, s, "import", "opml", filepath.Join(t.TempDir(), "missing.opml"))
+	if err == nil {
+		t.Error("expected an error importing a missing file")
+	}
+}
+
+func TestHandlerMigrate(t *testing.T) {
+	conn, _, driver, err := openDatabase("sqlite://" + filepath.Join(t.TempDir(), "gator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s := &state{conn: conn, driver: driver, cfg: &config.Config{}}
+
+	_, err = run(t, s, "migrate")
+	assertError(t, err, "usage: gator migrate")
+
+	if err := checkSchemaVersion(conn, driver); err == nil {
+		t.Error("expected an error for a database without a schema")
+	}
+
+	out := mustRun(t, s, "migrate", "up")
+	assertContains(t, out, "Applied ")
+
+	if err := checkSchemaVersion(conn, driver); err != nil {
+		t.Error(err)
+	}
+
+	out = mustRun(t, s, "migrate", "up")
+	assertContains(t, out, "already up to date")
+
+	out = mustRun(t, s, "migrate", "status")
+	assertContains(t, out, ": applied at ")
+
+	out = mustRun(t, s, "migrate", "down")
+	assertContains(t, out, "Rolled back ")
+
+	_, err = run(t, s, "migrate", "sideways")
+	assertError(t, err, `unknown migrate command "sideways"`)
+}
+
+// failingStore makes one query fail, to check that commands roll back the
+// steps that ran before it.
+type failingStore struct {
+	*memory.Store
+	query string
+}
+
+var errQueryFailed = errors.New("query failed")
+
+func (f *failingStore) Transaction(ctx context.Context, fn func(database.Querier) error) error {
+	return f.Store.Transaction(ctx, func(database.Querier) error {
+		return fn(f)
+	})
+}
+
+func (f *failingStore) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	if f.query == "CreateFeedFollow" {
+		return database.CreateFeedFollowRow{}, errQueryFailed
+	}
+	return f.Store.CreateFeedFollow(ctx, arg)
+}
+
+func (f *failingStore) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if f.query == "DeleteUser" {
+		return errQueryFailed
+	}
+	return f.Store.DeleteUser(ctx, id)
+}
+
+func (f *failingStore) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error {
+	if f.query == "DeleteFeedFollow" {
+		return errQueryFailed
+	}
+	return f.Store.DeleteFeedFollow(ctx, arg)
+}
+
+func (f *failingStore) MarkOrphanedFeeds(ctx context.Context, orphanedAt sql.NullTime) (int64, error) {
+	if f.query == "MarkOrphanedFeeds" {
+		return 0, errQueryFailed
+	}
+	return f.Store.MarkOrphanedFeeds(ctx, orphanedAt)
+}
+
+func TestHandlerUnfollowRollsBack(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+	s.db = &failingStore{Store: s.db.(*memory.Store), query: "MarkOrphanedFeeds"}
+
+	_, err := run(t, s, "unfollow", goURL)
+	if !errors.Is(err, errQueryFailed) {
+		t.Fatalf("expected marking the feed as orphaned to fail, got %v", err)
+	}
+
+	out := mustRun(t, s, "following")
+	assertContains(t, out, goURL)
+}
+
+func TestHandlerAddFeedRollsBack(t *testing.T) {
+	s := newTestState(t)
+	mustRun(t, s, "register", "alice")
+	s.db = &failingStore{Store: s.db.(*memory.Store), query: "CreateFeedFollow"}
+
+	_, err := run(t, s, "addfeed", "Go Blog", "https://example.com/go")
+	if !errors.Is(err, errQueryFailed) {
+		t.Fatalf("expected the follow to fail, got %v", err)
+	}
+
+	if _, err := s.db.GetFeedByUrl(context.Background(), "https://example.com/go"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the feed to be rolled back, got %v", err)
+	}
+}
+
+func TestHandlerImportRollsBack(t *testing.T) {
+	s := newTestState(t)
+	mustRun(t, s, "register", "alice")
+	s.db = &failingStore{Store: s.db.(*memory.Store), query: "CreateFeedFollow"}
+
+	path := filepath.Join(t.TempDir(), "feeds.opml")
+	err := os.WriteFile(path, []byte(`<opml version="2.0"><body>
+<outline text="Broken" xmlUrl="ftp://example.com/feed.xml"/>
+<outline text="Example" xmlUrl="https://example.com/feed.xml"/>
+</body></opml>`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := run(t, s, "import", "opml", path)
+	assertError(t, err, "nothing was imported")
+	if out != "" {
+		t.Errorf("expected nothing to be reported for a rolled back import, got:\n%s", out)
+	}
+
+	feeds, _ := s.db.GetFeeds(context.Background())
+	if len(feeds) != 0 {
+		t.Errorf("expected the imported feeds to be rolled back, got %d", len(feeds))
+	}
+}
+
+func TestHandlerUserDeleteRollsBack(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+	mustRun(t, s, "register", "bob")
+	mustRun(t, s, "follow", goURL)
+	s.db = &failingStore{Store: s.db.(*memory.Store), query: "DeleteUser"}
+
+	_, err := run(t, s, "user", "delete", "alice")
+	if !errors.Is(err, errQueryFailed) {
+		t.Fatalf("expected the delete to fail, got %v", err)
+	}
+
+	out := mustRun(t, s, "feeds")
+	assertRow(t, out, "Go Blog", goURL, "alice")
+}
+
+func TestHandlerFeedRemoveRollsBack(t *testing.T) {
+	s, _, goURL, _ := newTestFeeds(t)
+	mustRun(t, s, "register", "bob")
+	mustRun(t, s, "follow", goURL)
+	mustRun(t, s, "login", "alice")
+	s.db = &failingStore{Store: s.db.(*memory.Store), query: "DeleteFeedFollow"}
+
+	_, err := run(t, s, "feed", "rm", goURL)
+	if !errors.Is(err, errQueryFailed) {
+		t.Fatalf("expected the unfollow to fail, got %v", err)
+	}
+
+	out := mustRun(t, s, "feeds")
+	assertRow(t, out, "Go Blog", goURL, "alice")
+}
+
+// outsideTxStore records the lookups that ran outside a transaction, since
+// memory.Store hands itself rather than the wrapper to transactions.
+type outsideTxStore struct {
+	*memory.Store
+	queries []string
+}
+
+func (o *outsideTxStore) GetFeedByUrl(ctx context.Context, url string) (database.Feed, error) {
+	o.queries = append(o.queries, "GetFeedByUrl")
+	return o.Store.GetFeedByUrl(ctx, url)
+}
+
+func (o *outsideTxStore) GetNextFeedFollower(ctx context.Context, arg database.GetNextFeedFollowerParams) (uuid.UUID, error) {
+	o.queries = append(o.queries, "GetNextFeedFollower")
+	return o.Store.GetNextFeedFollower(ctx, arg)
+}
+
+func TestHandlerFeedRemoveLooksUpInTx(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+	mustRun(t, s, "register", "bob")
+	mustRun(t, s, "follow", goURL)
+	mustRun(t, s, "login", "alice")
+	store := &outsideTxStore{Store: s.db.(*memory.Store)}
+	s.db = store
+
+	mustRun(t, s, "feed", "rm", goURL)
+	mustRun(t, s, "feed", "rm", rustURL)
+
+	if len(store.queries) > 0 {
+		t.Errorf("expected feed rm to look the feed up in its transaction, ran %q outside", store.queries)
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	s, server, goURL, _ := newTestFeeds(t)
+
+	var users []userRow
+	decodeJSON(t, mustRun(t, s, "users", "--output", "json"), &users)
+	if len(users) != 1 || users[0].Name != "alice" || !users[0].Current {
+		t.Errorf("unexpected users: %+v", users)
+	}
+
+	var feeds []feedRow
+	decodeJSON(t, mustRun(t, s, "feeds", "--output", "json"), &feeds)
+	if len(feeds) != 2 || feeds[0].CreatedBy == nil || *feeds[0].CreatedBy != "alice" {
+		t.Errorf("unexpected feeds: %+v", feeds)
+	}
+
+	mustRun(t, s, "tag", goURL, "golang")
+	var following []followingRow
+	decodeJSON(t, mustRun(t, s, "following", "--output=json", "--tag", "golang"), &following)
+	if len(following) != 1 || following[0].URL != goURL || strings.Join(following[0].Tags, ",") != "golang" {
+		t.Errorf("unexpected follows: %+v", following)
+	}
+
+	var posts []postRow
+	decodeJSON(t, mustRun(t, s, "browse", "3", "--output", "json"), &posts)
+	if len(posts) != 3 || posts[0].Title != "Async Rust" || posts[0].Feed != "Rust Blog" || posts[0].Cursor == "" {
+		t.Fatalf("unexpected posts: %+v", posts)
+	}
+
+	out := mustRun(t, s, "browse", "--cursor", posts[2].Cursor, "--output", "json")
+	assertContains(t, out, `"title": "Generics in Go"`)
+	assertNotContains(t, out, "Next page")
+
+	out = mustRun(t, s, "search", "nothing-matches-this", "--output", "json")
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("expected an empty list, got %q", out)
+	}
+
+	var added addedFeedRow
+	url := server.setFeed("/zig", "Zig Blog")
+	decodeJSON(t, mustRun(t, s, "addfeed", url, "--output", "json"), &added)
+	if added.Name != "Zig Blog" || !added.Added || !added.Followed {
+		t.Errorf("unexpected feed: %+v", added)
+	}
+
+	decodeJSON(t, mustRun(t, s, "addfeed", url, "--output", "json"), &added)
+	if added.Added || added.Followed {
+		t.Errorf("expected the feed to exist already: %+v", added)
+	}
+}
+
+func TestOutputCSV(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+
+	out := mustRun(t, s, "feeds", "--output", "csv")
+	assertContains(t, out,
+		"name,url,created_by,site_link,last_fetched_at,created_at\n",
+		"Go Blog,"+goURL+",alice,https://example.com/go,",
+		"Rust Blog,"+rustURL+",alice,",
+	)
+
+	// Times are written the same way as in JSON.
+	var feeds []struct {
+		CreatedAt string `json:"created_at"`
+	}
+	decodeJSON(t, mustRun(t, s, "feeds", "--output", "json"), &feeds)
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[1][5] != feeds[0].CreatedAt {
+		t.Errorf("expected created_at %q in CSV to match JSON, got %q", feeds[0].CreatedAt, records[1][5])
+	}
+
+	mustRun(t, s, "tag", goURL, "golang", "blogs")
+	out = mustRun(t, s, "following", "--output", "csv")
+	assertContains(t, out, "name,url,site_link,tags\n", "Go Blog,"+goURL+",https://example.com/go,blogs;golang\n")
+
+	_, err = run(t, s, "feeds", "--output", "xml")
+	assertError(t, err, `invalid value "xml" for flag -output: expected table, json or csv`)
+
+	_, err = run(t, s, "follow", goURL, "--output", "json")
+	assertError(t, err, "flag provided but not defined: -output")
+}
+
+func decodeJSON(t *testing.T, out string, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal([]byte(out), v); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/thihxm/gator/internal/database"
+)
+
+// completeCommand is the hidden command the completion scripts run to get
+// the candidates for the word being completed.
+const completeCommand = "__complete"
+
+// completer returns the candidates for one of a command's arguments, given
+// the arguments before it. Completers that look things up in the database
+// get it from open.
+type completer func(open stateOpener, args []string) ([]string, error)
+
+// stateOpener reads the config and opens the database, so that completing
+// command and flag names works before gator is set up.
+type stateOpener func() (*state, error)
+
+// flagCompleters complete the values of flags, which mean the same thing in
+// every command that has them.
+var flagCompleters = map[string]completer{
+	"feed":   completeFollowedFeeds,
+	"tag":    completeTags,
+	"sort":   completeWords("fetched", "published"),
+	"output": completeWords(outputTable, outputJSON, outputCSV),
+}
+
+const bashCompletion = `# bash completion for gator
+
+_gator() {
+    local line="${COMP_LINE:0:COMP_POINT}"
+    local -a words
+    read -ra words <<< "$line"
+    [[ $line == *[[:space:]] ]] && words+=("")
+    local cur="${words[${#words[@]}-1]}"
+
+    local IFS=$'\n'
+    COMPREPLY=($(command gator __complete "${words[@]:1}" 2>/dev/null))
+
+    # Bash splits words on colons, so only the part of a URL after the last
+    # one gets replaced.
+    if [[ $cur == *:* && $COMP_WORDBREAKS == *:* ]]; then
+        local prefix="${cur%"${cur##*:}"}"
+        COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+    fi
+}
+
+complete -o default -F _gator gator
+`
+
+const zshCompletion = `#compdef gator
+
+_gator() {
+    local -a candidates
+    candidates=("${(@f)$(command gator __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    candidates=("${(@)candidates:#}")
+
+    if (( ${#candidates} )); then
+        compadd -Q -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+
+if [[ $funcstack[1] == _gator ]]; then
+    _gator "$@"
+else
+    compdef _gator gator
+fi
+`
+
+const fishCompletion = `# fish completion for gator
+
+function __gator_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    command gator __complete $args (commandline -ct) 2>/dev/null
+end
+
+complete -c gator -f -a '(__gator_complete)'
+complete -c gator -n '__fish_seen_subcommand_from opml' -F
+`
+
+func handlerCompletion(s *state, cmd command) error {
+	switch cmd.args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		return fmt.Errorf("unsupported shell %q, expected bash, zsh or fish", cmd.args[0])
+	}
+
+	return nil
+}
+
+// printCompletions prints the candidates for the last of words, the words
+// of a command line after gator, one per line.
+func (c *commands) printCompletions(open stateOpener, words []string) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+
+	for _, candidate := range c.complete(open, words[:len(words)-1], words[len(words)-1]) {
+		fmt.Println(candidate)
+	}
+}
+
+// complete returns the candidates starting with word for the command line
+// made of args and word: command and subcommand names, flag names and
+// values, or the arguments of the command. Arguments whose candidates can't
+// be listed, such as when nobody is logged in or the database can't be
+// opened, have none.
+func (c *commands) complete(open stateOpener, args []string, word string) []string {
+	if len(args) == 0 {
+		return matching(commandNames(c.specs), word)
+	}
+
+	spec, ok := c.lookup(args[0])
+	if !ok {
+		return nil
+	}
+	name := spec.name
+	args = args[1:]
+
+	for len(spec.subcommands) > 0 && len(args) > 0 {
+		if spec, ok = spec.subcommand(args[0]); !ok {
+			return nil
+		}
+		name += " " + spec.name
+		args = args[1:]
+	}
+
+	if len(spec.subcommands) > 0 {
+		return matching(commandNames(spec.subcommands), word)
+	}
+
+	fs, _ := spec.flagSet(name)
+	positional, flagName := splitArgs(fs, args)
+
+	var complete completer
+	switch {
+	case flagName != "":
+		complete = flagCompleters[flagName]
+	case strings.HasPrefix(word, "-"):
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) { names = append(names, "--"+f.Name) })
+		return matching(append(names, "--help"), word)
+	case len(positional) < len(spec.complete):
+		complete = spec.complete[len(positional)]
+	case len(spec.complete) > 0 && strings.HasSuffix(spec.usage, "..."):
+		complete = spec.complete[len(spec.complete)-1]
+	}
+
+	if complete == nil {
+		return nil
+	}
+
+	candidates, err := complete(open, positional)
+	if err != nil {
+		return nil
+	}
+
+	return matching(candidates, word)
+}
+
+// splitArgs returns the positional arguments among args, and the name of
+// the flag the next argument is the value of, if any.
+func splitArgs(fs *flag.FlagSet, args []string) ([]string, string) {
+	var positional []string
+	flagName := ""
+
+	for _, arg := range args {
+		if flagName != "" {
+			flagName = ""
+			continue
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && boolFlag.IsBoolFlag() {
+			continue
+		}
+		flagName = name
+	}
+
+	return positional, flagName
+}
+
+func commandNames(specs []commandSpec) []string {
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.name
+	}
+	return names
+}
+
+func matching(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// completeHelp completes the command, then the subcommand, help is asked
+// about.
+func (c *commands) completeHelp(open stateOpener, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return commandNames(c.specs), nil
+	}
+
+	spec, ok := c.lookup(args[0])
+	if !ok {
+		return nil, nil
+	}
+	return commandNames(spec.subcommands), nil
+}
+
+func completeWords(words ...string) completer {
+	return func(stateOpener, []string) ([]string, error) {
+		return words, nil
+	}
+}
+
+func completeUsers(open stateOpener, args []string) ([]string, error) {
+	s, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(users))
+	for i, user := range users {
+		names[i] = user.Name
+	}
+	return names, nil
+}
+
+// completeFeeds completes the URL of any feed that has been added.
+func completeFeeds(open stateOpener, args []string) ([]string, error) {
+	s, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, len(feeds))
+	for i, feed := range feeds {
+		urls[i] = feed.Url
+	}
+	return urls, nil
+}
+
+func completeFollowedFeeds(open stateOpener, args []string) ([]string, error) {
+	s, user, err := completionUser(open)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := s.db.GetFeedFollowsForUser(
+		context.Background(),
+		database.GetFeedFollowsForUserParams{UserID: user.ID},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, len(follows))
+	for i, follow := range follows {
+		urls[i] = follow.FeedUrl
+	}
+	return urls, nil
+}
+
+// completeOwnedFeeds completes the URLs of the feeds the user added, which
+// are the ones the feed subcommands manage.
+func completeOwnedFeeds(open stateOpener, args []string) ([]string, error) {
+	s, user, err := completionUser(open)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds, err := s.db.GetFeedsOwnedByUser(
+		context.Background(),
+		uuid.NullUUID{UUID: user.ID, Valid: true},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, len(feeds))
+	for i, feed := range feeds {
+		urls[i] = feed.Url
+	}
+	return urls, nil
+}
+
+func completeTags(open stateOpener, args []string) ([]string, error) {
+	s, user, err := completionUser(open)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := s.db.GetTagsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names, nil
+}
+
+// completionUser opens the state and returns the logged in user, whose feeds
+// and tags are completed.
+func completionUser(open stateOpener) (*state, database.User, error) {
+	s, err := open()
+	if err != nil {
+		return nil, database.User{}, err
+	}
+
+	if s.cfg.CurrentUserName == nil {
+		return nil, database.User{}, errors.New("not logged in")
+	}
+
+	user, err := s.db.GetUser(context.Background(), *s.cfg.CurrentUserName)
+	return s, user, err
+}
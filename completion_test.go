@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestHandlerCompletion(t *testing.T) {
+	s := newTestState(t)
+
+	out := mustRun(t, s, "completion", "bash")
+	assertContains(t, out, "complete -o default -F _gator gator", "gator __complete")
+
+	out = mustRun(t, s, "completion", "zsh")
+	assertContains(t, out, "#compdef gator", "gator __complete")
+
+	out = mustRun(t, s, "completion", "fish")
+	assertContains(t, out, "complete -c gator", "gator __complete")
+
+	_, err := run(t, s, "completion", "tcsh")
+	assertError(t, err, `unsupported shell "tcsh"`)
+}
+
+func TestComplete(t *testing.T) {
+	s, _, goURL, rustURL := newTestFeeds(t)
+	mustRun(t, s, "tag", goURL, "lang")
+	mustRun(t, s, "register", "bob")
+	mustRun(t, s, "login", "alice")
+
+	cmds := newCommands()
+	open := func() (*state, error) { return s, nil }
+	tests := []struct {
+		args []string
+		word string
+		want []string
+	}{
+		{nil, "fe", []string{"feeds", "feed"}},
+		{[]string{"feed"}, "re", []string{"rename", "retention"}},
+		{[]string{"feed", "rm"}, "", []string{goURL, rustURL}},
+		{[]string{"follow"}, "", []string{goURL, rustURL}},
+		{[]string{"unfollow"}, goURL[:len(goURL)-1], []string{goURL}},
+		{[]string{"tag"}, "", []string{goURL, rustURL}},
+		{[]string{"tag", goURL}, "", []string{"lang"}},
+		{[]string{"tag", goURL, "lang"}, "l", []string{"lang"}},
+		{[]string{"title", goURL}, "", nil},
+		{[]string{"login"}, "", []string{"alice", "bob"}},
+		{[]string{"user", "delete"}, "b", []string{"bob"}},
+		{[]string{"browse"}, "--ta", []string{"--tag"}},
+		{[]string{"browse", "--tag"}, "", []string{"lang"}},
+		{[]string{"browse", "--unread", "--feed"}, "", []string{goURL, rustURL}},
+		{[]string{"browse", "--feed", goURL}, "", nil},
+		{[]string{"browse", "--output"}, "j", []string{"json"}},
+		{[]string{"migrate"}, "", []string{"up", "down", "status"}},
+		{[]string{"completion"}, "z", []string{"zsh"}},
+		{[]string{"help"}, "mi", []string{"migrate"}},
+		{[]string{"help", "feed"}, "r", []string{"rm", "rename", "retention"}},
+		{[]string{"explode"}, "", nil},
+	}
+
+	for _, tt := range tests {
+		got := cmds.complete(open, tt.args, tt.word)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("complete(%q, %q) = %q, want %q", tt.args, tt.word, got, tt.want)
+		}
+	}
+
+	out, err := capture(t, func() error {
+		cmds.printCompletions(open, []string{"unfollow", ""})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != goURL+"\n"+rustURL+"\n" {
+		t.Errorf("unexpected completions:\n%s", out)
+	}
+
+	// Feeds and tags are those of the logged in user, so there are none when
+	// nobody is.
+	s.cfg.CurrentUserName = nil
+	if got := cmds.complete(open, []string{"unfollow"}, ""); got != nil {
+		t.Errorf("expected no completions when logged out, got %q", got)
+	}
+	if got := cmds.complete(open, []string{"login"}, "a"); !slices.Equal(got, []string{"alice"}) {
+		t.Errorf("expected usernames to be completed when logged out, got %q", got)
+	}
+}
+
+func TestCompleteWithoutDatabase(t *testing.T) {
+	cmds := newCommands()
+	opened := 0
+	open := func() (*state, error) {
+		opened++
+		return nil, errors.New("no config")
+	}
+
+	tests := []struct {
+		args []string
+		word string
+		want []string
+	}{
+		{nil, "fe", []string{"feeds", "feed"}},
+		{[]string{"feed"}, "re", []string{"rename", "retention"}},
+		{[]string{"browse"}, "--ta", []string{"--tag"}},
+		{[]string{"browse", "--sort"}, "p", []string{"published"}},
+		{[]string{"completion"}, "b", []string{"bash"}},
+		{[]string{"help", "feed"}, "rm", []string{"rm"}},
+	}
+
+	for _, tt := range tests {
+		got := cmds.complete(open, tt.args, tt.word)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("complete(%q, %q) = %q, want %q", tt.args, tt.word, got, tt.want)
+		}
+	}
+	if opened != 0 {
+		t.Errorf("expected names to be completed without opening the database, opened it %d times", opened)
+	}
+
+	out, err := capture(t, func() error {
+		cmds.printCompletions(open, []string{"unfollow", ""})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" {
+		t.Errorf("expected no completions when the database can't be opened, got:\n%s", out)
+	}
+}
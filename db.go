@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/thihxm/gator/internal/database"
+	"github.com/thihxm/gator/internal/sqlite"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	driverPostgres = "postgres"
+	driverSQLite   = "sqlite"
+)
+
+//go:embed sql/sqlite/queries/*.sql
+var sqliteQueriesFS embed.FS
+
+// transactor is implemented by stores that can run a group of queries in a
+// single transaction.
+type transactor interface {
+	Transaction(ctx context.Context, fn func(database.Querier) error) error
+}
+
+// sqlStore runs the queries against a database connection.
+type sqlStore struct {
+	*database.Queries
+	conn   *sql.DB
+	withTx func(tx *sql.Tx) *database.Queries
+}
+
+// Transaction runs fn with queries bound to a new transaction, which is
+// committed if fn returns nil and rolled back otherwise.
+func (s *sqlStore) Transaction(ctx context.Context, fn func(database.Querier) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(s.withTx(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withTx runs fn with a copy of s whose queries all run in one transaction,
+// so multi-step commands don't leave partial changes behind when a step
+// fails. Stores that can't start a transaction, such as one that's already
+// running in one, run fn directly.
+func withTx(s *state, fn func(tx *state) error) error {
+	store, ok := s.db.(transactor)
+	if !ok {
+		return fn(s)
+	}
+
+	return store.Transaction(context.Background(), func(queries database.Querier) error {
+		tx := *s
+		tx.db = queries
+		return fn(&tx)
+	})
+}
+
+// openDatabase connects to the database in dbURL. URLs starting with
+// sqlite:// (or sqlite:) open an SQLite database file, anything else is
+// treated as a PostgreSQL connection string.
+func openDatabase(dbURL string) (*sql.DB, *sqlStore, string, error) {
+	path, ok := sqlitePath(dbURL)
+	if !ok {
+		db, err := sql.Open(driverPostgres, dbURL)
+		if err != nil {
+			return nil, nil, "", err
+		}
+
+		queries := database.New(db)
+		store := &sqlStore{Queries: queries, conn: db, withTx: queries.WithTx}
+
+		return db, store, driverPostgres, nil
+	}
+
+	if path == "" {
+		return nil, nil, "", fmt.Errorf("missing SQLite database path in %q", dbURL)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"},
+	}.Encode()
+	db, err := sql.Open(driverSQLite, dsn)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	queries, err := fs.Sub(sqliteQueriesFS, "sql/sqlite/queries")
+	if err != nil {
+		db.Close()
+		return nil, nil, "", err
+	}
+
+	dbtx, err := sqlite.New(db, queries)
+	if err != nil {
+		db.Close()
+		return nil, nil, "", err
+	}
+
+	store := &sqlStore{
+		Queries: database.New(dbtx),
+		conn:    db,
+		withTx: func(tx *sql.Tx) *database.Queries {
+			return database.New(dbtx.WithTx(tx))
+		},
+	}
+
+	return db, store, driverSQLite, nil
+}
+
+// sqlitePath returns the database file path of an sqlite:// URL, expanding a
+// leading ~ to the home directory.
+func sqlitePath(dbURL string) (string, bool) {
+	path, ok := strings.CutPrefix(dbURL, "sqlite://")
+	if !ok {
+		path, ok = strings.CutPrefix(dbURL, "sqlite:")
+	}
+	if !ok {
+		return "", false
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+
+	return path, true
+}
+
+// uniqueViolationMessages explain which unique constraint a command broke,
+// keyed by the PostgreSQL constraint name.
+var uniqueViolationMessages = map[string]string{
+	"users_name_key": "a user with that name already exists",
+	"feeds_url_key":  "a feed with that URL already exists",
+	"uc_user_feed":   "you are already following that feed",
+	"posts_url_key":  "a post with that URL already exists",
+	"uc_user_tag":    "you already have a tag with that name",
+}
+
+// sqliteConstraints maps the columns SQLite reports in a unique violation to
+// the name of the matching PostgreSQL constraint.
+var sqliteConstraints = map[string]string{
+	"users.name": "users_name_key",
+	"feeds.url":  "feeds_url_key",
+	"feed_follows.user_id, feed_follows.feed_id": "uc_user_feed",
+	"posts.url":               "posts_url_key",
+	"tags.user_id, tags.name": "uc_user_tag",
+}
+
+// friendlyError replaces constraint violations reported by the database with
+// a message that makes sense to someone running gator. Other errors are
+// returned as is.
+func friendlyError(err error) error {
+	var constraint string
+
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &pqErr) && pqErr.Code == "23503":
+		return errors.New("the user, feed or post it refers to no longer exists")
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		constraint = pqErr.Constraint
+	case sqlite.IsUniqueViolation(err):
+		constraint = sqliteConstraints[sqlite.ConstraintColumns(err)]
+	default:
+		return err
+	}
+
+	if message, ok := uniqueViolationMessages[constraint]; ok {
+		return errors.New(message)
+	}
+
+	return errors.New("that already exists")
+}
+
+// isUniqueViolation reports whether err was caused by inserting a row that
+// already exists, on either database backend.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	return sqlite.IsUniqueViolation(err)
+}
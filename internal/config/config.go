@@ -2,14 +2,23 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"time"
 )
 
 const configFileName = ".gatorconfig.json"
 
+// DefaultFeedGCGracePeriod is how long a feed can go without followers
+// before gc deletes it, unless configured otherwise.
+const DefaultFeedGCGracePeriod = 30 * 24 * time.Hour
+
 type Config struct {
-	DB_URL          string  `json:"db_url"`
-	CurrentUserName *string `json:"current_user_name"`
+	DB_URL            string  `json:"db_url"`
+	CurrentUserName   *string `json:"current_user_name"`
+	FeedGCGracePeriod string  `json:"feed_gc_grace_period,omitempty"`
+	RetentionMaxAge   string  `json:"retention_max_age,omitempty"`
+	RetentionMaxPosts int     `json:"retention_max_posts,omitempty"`
 }
 
 func Read() (Config, error) {
@@ -44,6 +53,47 @@ func (c *Config) SetUser(userName string) error {
 	return nil
 }
 
+func (c *Config) ClearUser() error {
+	c.CurrentUserName = nil
+
+	err := write(*c)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GCGracePeriod returns the configured feed_gc_grace_period, falling back to
+// DefaultFeedGCGracePeriod when it isn't set.
+func (c *Config) GCGracePeriod() (time.Duration, error) {
+	if c.FeedGCGracePeriod == "" {
+		return DefaultFeedGCGracePeriod, nil
+	}
+
+	gracePeriod, err := time.ParseDuration(c.FeedGCGracePeriod)
+	if err != nil {
+		return 0, fmt.Errorf("invalid feed_gc_grace_period: %w", err)
+	}
+
+	return gracePeriod, nil
+}
+
+// RetentionMaxAgeDuration returns the configured retention_max_age, or zero
+// when posts should be kept regardless of their age.
+func (c *Config) RetentionMaxAgeDuration() (time.Duration, error) {
+	if c.RetentionMaxAge == "" {
+		return 0, nil
+	}
+
+	maxAge, err := time.ParseDuration(c.RetentionMaxAge)
+	if err != nil {
+		return 0, fmt.Errorf("invalid retention_max_age: %w", err)
+	}
+
+	return maxAge, nil
+}
+
 func getConfigFilePath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -7,9 +7,11 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createFeedFollow = `-- name: CreateFeedFollow :one
@@ -22,11 +24,11 @@ WITH inserted_feed_follow AS (
         $4,
         $5
     )
-    RETURNING id, created_at, updated_at, user_id, feed_id
+    RETURNING id, created_at, updated_at, user_id, feed_id, display_name, retention_max_age_seconds, retention_max_posts
 )
 SELECT
-    inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id,
-    feeds.name AS feed_name,
+    inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.display_name, inserted_feed_follow.retention_max_age_seconds, inserted_feed_follow.retention_max_posts,
+    COALESCE(inserted_feed_follow.display_name, feeds.name)::text AS feed_name,
     users.name AS user_name
 FROM inserted_feed_follow
 INNER JOIN users ON inserted_feed_follow.user_id = users.id
@@ -42,13 +44,16 @@ type CreateFeedFollowParams struct {
 }
 
 type CreateFeedFollowRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
-	FeedName  string
-	UserName  string
+	ID                     uuid.UUID
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+	UserID                 uuid.UUID
+	FeedID                 uuid.UUID
+	DisplayName            sql.NullString
+	RetentionMaxAgeSeconds sql.NullInt64
+	RetentionMaxPosts      sql.NullInt32
+	FeedName               string
+	UserName               string
 }
 
 func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (CreateFeedFollowRow, error) {
@@ -66,6 +71,9 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.DisplayName,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
 		&i.FeedName,
 		&i.UserName,
 	)
@@ -91,20 +99,137 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
+const getFeedFollow = `-- name: GetFeedFollow :one
+SELECT id, created_at, updated_at, user_id, feed_id, display_name, retention_max_age_seconds, retention_max_posts FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollow(ctx context.Context, arg GetFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollow, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.DisplayName,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+	)
+	return i, err
+}
+
+const getFeedFollowByUrl = `-- name: GetFeedFollowByUrl :one
+SELECT feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.display_name, feed_follows.retention_max_age_seconds, feed_follows.retention_max_posts FROM feed_follows
+INNER JOIN feeds ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1 AND feeds.url = $2
+`
+
+type GetFeedFollowByUrlParams struct {
+	UserID uuid.UUID
+	Url    string
+}
+
+func (q *Queries) GetFeedFollowByUrl(ctx context.Context, arg GetFeedFollowByUrlParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollowByUrl, arg.UserID, arg.Url)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.DisplayName,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+	)
+	return i, err
+}
+
+const getFeedFollowRetentions = `-- name: GetFeedFollowRetentions :many
+SELECT retention_max_age_seconds, retention_max_posts FROM feed_follows
+WHERE feed_id = $1
+`
+
+type GetFeedFollowRetentionsRow struct {
+	RetentionMaxAgeSeconds sql.NullInt64
+	RetentionMaxPosts      sql.NullInt32
+}
+
+func (q *Queries) GetFeedFollowRetentions(ctx context.Context, feedID uuid.UUID) ([]GetFeedFollowRetentionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowRetentions, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedFollowRetentionsRow
+	for rows.Next() {
+		var i GetFeedFollowRetentionsRow
+		if err := rows.Scan(&i.RetentionMaxAgeSeconds, &i.RetentionMaxPosts); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
-SELECT feeds.name as feed_name, users.name as user_name FROM feed_follows
+SELECT
+    COALESCE(feed_follows.display_name, feeds.name)::text as feed_name,
+    feeds.url as feed_url,
+    feeds.site_link as feed_site_link,
+    users.name as user_name,
+    COALESCE(
+        array_agg(tags.name ORDER BY tags.name) FILTER (WHERE tags.name IS NOT NULL),
+        '{}'
+    )::text[] AS tags
+FROM feed_follows
 INNER JOIN users ON feed_follows.user_id = users.id
 INNER JOIN feeds ON feed_follows.feed_id = feeds.id
-WHERE feed_follows.user_id = $1
+LEFT JOIN feed_follow_tags ON feed_follows.id = feed_follow_tags.feed_follow_id
+LEFT JOIN tags ON feed_follow_tags.tag_id = tags.id
+WHERE
+    feed_follows.user_id = $1
+    AND (
+        $2::text IS NULL
+        OR EXISTS (
+            SELECT 1 FROM feed_follow_tags AS tagged
+            INNER JOIN tags AS filter_tags ON tagged.tag_id = filter_tags.id
+            WHERE tagged.feed_follow_id = feed_follows.id
+                AND filter_tags.name = $2
+        )
+    )
+GROUP BY feed_follows.id, feeds.id, users.id
+ORDER BY feed_name
 `
 
+type GetFeedFollowsForUserParams struct {
+	UserID uuid.UUID
+	Tag    sql.NullString
+}
+
 type GetFeedFollowsForUserRow struct {
-	FeedName string
-	UserName string
+	FeedName     string
+	FeedUrl      string
+	FeedSiteLink sql.NullString
+	UserName     string
+	Tags         []string
 }
 
-func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, userID)
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, arg GetFeedFollowsForUserParams) ([]GetFeedFollowsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, arg.UserID, arg.Tag)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +237,13 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	var items []GetFeedFollowsForUserRow
 	for rows.Next() {
 		var i GetFeedFollowsForUserRow
-		if err := rows.Scan(&i.FeedName, &i.UserName); err != nil {
+		if err := rows.Scan(
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.FeedSiteLink,
+			&i.UserName,
+			pq.Array(&i.Tags),
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -125,3 +256,93 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const getNextFeedFollower = `-- name: GetNextFeedFollower :one
+SELECT feed_follows.user_id FROM feed_follows
+WHERE feed_follows.feed_id = $1 AND feed_follows.user_id <> $2
+ORDER BY feed_follows.created_at ASC
+LIMIT 1
+`
+
+type GetNextFeedFollowerParams struct {
+	FeedID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetNextFeedFollower(ctx context.Context, arg GetNextFeedFollowerParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getNextFeedFollower, arg.FeedID, arg.UserID)
+	var user_id uuid.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const setFeedFollowDisplayName = `-- name: SetFeedFollowDisplayName :execrows
+UPDATE feed_follows
+SET
+    display_name = $3,
+    updated_at = $4
+WHERE
+    feed_follows.user_id = $1
+    AND feed_follows.feed_id = (
+        SELECT id FROM feeds WHERE url = $2
+    )
+`
+
+type SetFeedFollowDisplayNameParams struct {
+	UserID      uuid.UUID
+	Url         string
+	DisplayName sql.NullString
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) SetFeedFollowDisplayName(ctx context.Context, arg SetFeedFollowDisplayNameParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedFollowDisplayName,
+		arg.UserID,
+		arg.Url,
+		arg.DisplayName,
+		arg.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setFeedFollowRetention = `-- name: SetFeedFollowRetention :one
+UPDATE feed_follows
+SET
+    retention_max_age_seconds = $2,
+    retention_max_posts = $3,
+    updated_at = $4
+WHERE
+    id = $1
+RETURNING id, created_at, updated_at, user_id, feed_id, display_name, retention_max_age_seconds, retention_max_posts
+`
+
+type SetFeedFollowRetentionParams struct {
+	ID                     uuid.UUID
+	RetentionMaxAgeSeconds sql.NullInt64
+	RetentionMaxPosts      sql.NullInt32
+	UpdatedAt              time.Time
+}
+
+func (q *Queries) SetFeedFollowRetention(ctx context.Context, arg SetFeedFollowRetentionParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFollowRetention,
+		arg.ID,
+		arg.RetentionMaxAgeSeconds,
+		arg.RetentionMaxPosts,
+		arg.UpdatedAt,
+	)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.DisplayName,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+	)
+	return i, err
+}
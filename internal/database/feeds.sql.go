@@ -13,6 +13,24 @@ import (
 	"github.com/google/uuid"
 )
 
+const clearFollowedFeedsOrphanedAt = `-- name: ClearFollowedFeedsOrphanedAt :execrows
+UPDATE feeds
+SET orphaned_at = NULL
+WHERE
+    orphaned_at IS NOT NULL
+    AND EXISTS (
+        SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id
+    )
+`
+
+func (q *Queries) ClearFollowedFeedsOrphanedAt(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, clearFollowedFeedsOrphanedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES (
@@ -23,7 +41,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, site_link, description, language, image_url, generator, orphaned_at
 `
 
 type CreateFeedParams struct {
@@ -32,7 +50,7 @@ type CreateFeedParams struct {
 	UpdatedAt time.Time
 	Name      string
 	Url       string
-	UserID    uuid.UUID
+	UserID    uuid.NullUUID
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -53,12 +71,48 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.OrphanedAt,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const deleteFeedsOrphanedBefore = `-- name: DeleteFeedsOrphanedBefore :execrows
+DELETE FROM feeds
+WHERE
+    orphaned_at < $1
+    AND NOT EXISTS (
+        SELECT 1 FROM posts AS starred_posts
+        INNER JOIN saved_posts ON saved_posts.post_id = starred_posts.id
+        WHERE starred_posts.feed_id = feeds.id
+    )
+`
+
+// Feeds with starred posts are kept, since deleting the feed would delete the
+// posts along with it.
+func (q *Queries) DeleteFeedsOrphanedBefore(ctx context.Context, orphanedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeedsOrphanedBefore, orphanedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getFeedByUrl = `-- name: GetFeedByUrl :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds WHERE url = $1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, site_link, description, language, image_url, generator, orphaned_at FROM feeds WHERE url = $1
 `
 
 func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
@@ -72,13 +126,19 @@ func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.OrphanedAt,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, users.name as user_name FROM feeds
-INNER JOIN users ON feeds.user_id = users.id
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.site_link, feeds.description, feeds.language, feeds.image_url, feeds.generator, feeds.orphaned_at, users.name as user_name FROM feeds
+LEFT JOIN users ON feeds.user_id = users.id
 `
 
 type GetFeedsRow struct {
@@ -87,9 +147,15 @@ type GetFeedsRow struct {
 	UpdatedAt     time.Time
 	Name          string
 	Url           string
-	UserID        uuid.UUID
+	UserID        uuid.NullUUID
 	LastFetchedAt sql.NullTime
-	UserName      string
+	SiteLink      sql.NullString
+	Description   sql.NullString
+	Language      sql.NullString
+	ImageUrl      sql.NullString
+	Generator     sql.NullString
+	OrphanedAt    sql.NullTime
+	UserName      sql.NullString
 }
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
@@ -109,6 +175,12 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.SiteLink,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.Generator,
+			&i.OrphanedAt,
 			&i.UserName,
 		); err != nil {
 			return nil, err
@@ -124,8 +196,120 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
+const getFeedsOrphanedBefore = `-- name: GetFeedsOrphanedBefore :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.site_link, feeds.description, feeds.language, feeds.image_url, feeds.generator, feeds.orphaned_at, COUNT(posts.id) AS post_count FROM feeds
+LEFT JOIN posts ON posts.feed_id = feeds.id
+WHERE feeds.orphaned_at < $1
+    AND NOT EXISTS (
+        SELECT 1 FROM posts AS starred_posts
+        INNER JOIN saved_posts ON saved_posts.post_id = starred_posts.id
+        WHERE starred_posts.feed_id = feeds.id
+    )
+GROUP BY feeds.id
+ORDER BY feeds.orphaned_at
+`
+
+type GetFeedsOrphanedBeforeRow struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Name          string
+	Url           string
+	UserID        uuid.NullUUID
+	LastFetchedAt sql.NullTime
+	SiteLink      sql.NullString
+	Description   sql.NullString
+	Language      sql.NullString
+	ImageUrl      sql.NullString
+	Generator     sql.NullString
+	OrphanedAt    sql.NullTime
+	PostCount     int64
+}
+
+func (q *Queries) GetFeedsOrphanedBefore(ctx context.Context, orphanedAt sql.NullTime) ([]GetFeedsOrphanedBeforeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsOrphanedBefore, orphanedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedsOrphanedBeforeRow
+	for rows.Next() {
+		var i GetFeedsOrphanedBeforeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.SiteLink,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.Generator,
+			&i.OrphanedAt,
+			&i.PostCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsOwnedByUser = `-- name: GetFeedsOwnedByUser :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, site_link, description, language, image_url, generator, orphaned_at FROM feeds WHERE user_id = $1
+`
+
+func (q *Queries) GetFeedsOwnedByUser(ctx context.Context, userID uuid.NullUUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsOwnedByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.SiteLink,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.Generator,
+			&i.OrphanedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, site_link, description, language, image_url, generator, orphaned_at FROM feeds
+WHERE EXISTS (
+    SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id
+)
 ORDER BY
     last_fetched_at ASC NULLS FIRST
 LIMIT 1
@@ -142,6 +326,12 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.OrphanedAt,
 	)
 	return i, err
 }
@@ -153,7 +343,7 @@ SET
     updated_at = $3
 WHERE
     id = $1
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, site_link, description, language, image_url, generator, orphaned_at
 `
 
 type MarkFeedFetchedParams struct {
@@ -173,6 +363,187 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.OrphanedAt,
+	)
+	return i, err
+}
+
+const markOrphanedFeeds = `-- name: MarkOrphanedFeeds :execrows
+UPDATE feeds
+SET orphaned_at = $1
+WHERE
+    orphaned_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id
+    )
+`
+
+func (q *Queries) MarkOrphanedFeeds(ctx context.Context, orphanedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markOrphanedFeeds, orphanedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const renameFeed = `-- name: RenameFeed :one
+UPDATE feeds
+SET
+    name = $2,
+    updated_at = $3
+WHERE
+    id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, site_link, description, language, image_url, generator, orphaned_at
+`
+
+type RenameFeedParams struct {
+	ID        uuid.UUID
+	Name      string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) RenameFeed(ctx context.Context, arg RenameFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, renameFeed, arg.ID, arg.Name, arg.UpdatedAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.OrphanedAt,
+	)
+	return i, err
+}
+
+const resetFeeds = `-- name: ResetFeeds :exec
+DELETE FROM feeds
+`
+
+func (q *Queries) ResetFeeds(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, resetFeeds)
+	return err
+}
+
+const setFeedOwner = `-- name: SetFeedOwner :exec
+UPDATE feeds
+SET
+    user_id = $2,
+    updated_at = $3
+WHERE
+    id = $1
+`
+
+type SetFeedOwnerParams struct {
+	ID        uuid.UUID
+	UserID    uuid.NullUUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedOwner(ctx context.Context, arg SetFeedOwnerParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedOwner, arg.ID, arg.UserID, arg.UpdatedAt)
+	return err
+}
+
+const setFeedUrl = `-- name: SetFeedUrl :one
+UPDATE feeds
+SET
+    url = $2,
+    last_fetched_at = NULL,
+    updated_at = $3
+WHERE
+    id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, site_link, description, language, image_url, generator, orphaned_at
+`
+
+type SetFeedUrlParams struct {
+	ID        uuid.UUID
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedUrl(ctx context.Context, arg SetFeedUrlParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedUrl, arg.ID, arg.Url, arg.UpdatedAt)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.OrphanedAt,
+	)
+	return i, err
+}
+
+const updateFeedMetadata = `-- name: UpdateFeedMetadata :one
+UPDATE feeds
+SET
+    site_link = $2,
+    description = $3,
+    language = $4,
+    image_url = $5,
+    generator = $6,
+    updated_at = $7
+WHERE
+    id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, site_link, description, language, image_url, generator, orphaned_at
+`
+
+type UpdateFeedMetadataParams struct {
+	ID          uuid.UUID
+	SiteLink    sql.NullString
+	Description sql.NullString
+	Language    sql.NullString
+	ImageUrl    sql.NullString
+	Generator   sql.NullString
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) UpdateFeedMetadata(ctx context.Context, arg UpdateFeedMetadataParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedMetadata,
+		arg.ID,
+		arg.SiteLink,
+		arg.Description,
+		arg.Language,
+		arg.ImageUrl,
+		arg.Generator,
+		arg.UpdatedAt,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.OrphanedAt,
 	)
 	return i, err
 }
@@ -17,27 +17,63 @@ type Feed struct {
 	UpdatedAt     time.Time
 	Name          string
 	Url           string
-	UserID        uuid.UUID
+	UserID        uuid.NullUUID
 	LastFetchedAt sql.NullTime
+	SiteLink      sql.NullString
+	Description   sql.NullString
+	Language      sql.NullString
+	ImageUrl      sql.NullString
+	Generator     sql.NullString
+	OrphanedAt    sql.NullTime
 }
 
 type FeedFollow struct {
+	ID                     uuid.UUID
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+	UserID                 uuid.UUID
+	FeedID                 uuid.UUID
+	DisplayName            sql.NullString
+	RetentionMaxAgeSeconds sql.NullInt64
+	RetentionMaxPosts      sql.NullInt32
+}
+
+type FeedFollowTag struct {
+	FeedFollowID uuid.UUID
+	TagID        uuid.UUID
+}
+
+type Post struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        string
+	Url          string
+	Description  sql.NullString
+	PublishedAt  sql.NullTime
+	FeedID       uuid.UUID
+	Content      sql.NullString
+	SearchVector interface{}
+}
+
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type SavedPost struct {
+	UserID  uuid.UUID
+	PostID  uuid.UUID
+	SavedAt time.Time
+}
+
+type Tag struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
-	FeedID    uuid.UUID
-}
-
-type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	Name      string
 }
 
 type User struct {
@@ -11,10 +11,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content)
 VALUES (
     $1,
     $2,
@@ -23,9 +24,10 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, content, search_vector
 `
 
 type CreatePostParams struct {
@@ -37,6 +39,7 @@ type CreatePostParams struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Content     sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -49,6 +52,7 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Content,
 	)
 	var i Post
 	err := row.Scan(
@@ -60,26 +64,72 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Content,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
-const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id FROM posts
-INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
-INNER JOIN feeds ON posts.feed_id = feed_follows.feed_id
-WHERE feed_follows.user_id = $1
-ORDER BY posts.created_at DESC
-LIMIT $2
+const deletePosts = `-- name: DeletePosts :execrows
+DELETE FROM posts WHERE id = ANY($1::uuid[])
 `
 
-type GetPostsForUserParams struct {
+func (q *Queries) DeletePosts(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deletePosts, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getPostByUrl = `-- name: GetPostByUrl :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, content, search_vector FROM posts WHERE url = $1
+`
+
+func (q *Queries) GetPostByUrl(ctx context.Context, url string) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByUrl, url)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Content,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getPostsByIDPrefix = `-- name: GetPostsByIDPrefix :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content, posts.search_vector FROM posts
+WHERE
+    posts.id::text LIKE $1::text || '%'
+    AND (
+        EXISTS (
+            SELECT 1 FROM feed_follows
+            WHERE feed_follows.feed_id = posts.feed_id
+                AND feed_follows.user_id = $2
+        )
+        OR EXISTS (
+            SELECT 1 FROM saved_posts
+            WHERE saved_posts.post_id = posts.id
+                AND saved_posts.user_id = $2
+        )
+    )
+LIMIT 2
+`
+
+type GetPostsByIDPrefixParams struct {
+	Prefix string
 	UserID uuid.UUID
-	Limit  int32
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+func (q *Queries) GetPostsByIDPrefix(ctx context.Context, arg GetPostsByIDPrefixParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsByIDPrefix, arg.Prefix, arg.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +146,333 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Content,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForFeed = `-- name: GetPostsForFeed :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content, posts.search_vector, feeds.name AS feed_name FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feeds.url = $1
+ORDER BY posts.published_at DESC NULLS LAST, posts.created_at DESC
+LIMIT $2
+`
+
+type GetPostsForFeedParams struct {
+	Url   string
+	Limit int32
+}
+
+type GetPostsForFeedRow struct {
+	Post     Post
+	FeedName string
+}
+
+func (q *Queries) GetPostsForFeed(ctx context.Context, arg GetPostsForFeedParams) ([]GetPostsForFeedRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForFeed, arg.Url, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsForFeedRow
+	for rows.Next() {
+		var i GetPostsForFeedRow
+		if err := rows.Scan(
+			&i.Post.ID,
+			&i.Post.CreatedAt,
+			&i.Post.UpdatedAt,
+			&i.Post.Title,
+			&i.Post.Url,
+			&i.Post.Description,
+			&i.Post.PublishedAt,
+			&i.Post.FeedID,
+			&i.Post.Content,
+			&i.Post.SearchVector,
+			&i.FeedName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content, posts.search_vector,
+    COALESCE(feed_follows.display_name, feeds.name)::text AS feed_name,
+    EXISTS (
+        SELECT 1 FROM post_reads
+        WHERE post_reads.user_id = feed_follows.user_id
+            AND post_reads.post_id = posts.id
+    ) AS is_read,
+    EXISTS (
+        SELECT 1 FROM saved_posts
+        WHERE saved_posts.user_id = feed_follows.user_id
+            AND saved_posts.post_id = posts.id
+    ) AS is_starred
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+CROSS JOIN LATERAL (
+    SELECT
+        CASE
+            WHEN $1::text = 'published'
+                THEN COALESCE(posts.published_at, posts.created_at)
+            ELSE posts.created_at
+        END AS sort_at
+) AS sort_key
+WHERE
+    feed_follows.user_id = $2
+    AND ($3::text IS NULL OR feeds.url = $3)
+    AND (
+        $4::text IS NULL
+        OR EXISTS (
+            SELECT 1 FROM feed_follow_tags
+            INNER JOIN tags ON feed_follow_tags.tag_id = tags.id
+            WHERE feed_follow_tags.feed_follow_id = feed_follows.id
+                AND tags.name = $4
+        )
+    )
+    AND (
+        $5::boolean IS NULL
+        OR EXISTS (
+            SELECT 1 FROM post_reads
+            WHERE post_reads.user_id = feed_follows.user_id
+                AND post_reads.post_id = posts.id
+        ) = $5
+    )
+    AND ($6::timestamptz IS NULL OR sort_key.sort_at >= $6)
+    AND ($7::timestamptz IS NULL OR sort_key.sort_at < $7)
+    AND (
+        $8::timestamptz IS NULL
+        OR (sort_key.sort_at, posts.id) < ($8, $9::uuid)
+    )
+ORDER BY
+    sort_key.sort_at DESC,
+    posts.id DESC
+LIMIT $10
+`
+
+type GetPostsForUserParams struct {
+	SortBy   string
+	UserID   uuid.UUID
+	FeedUrl  sql.NullString
+	Tag      sql.NullString
+	IsRead   sql.NullBool
+	Since    sql.NullTime
+	Until    sql.NullTime
+	CursorAt sql.NullTime
+	CursorID uuid.NullUUID
+	MaxPosts int32
+}
+
+type GetPostsForUserRow struct {
+	Post      Post
+	FeedName  string
+	IsRead    bool
+	IsStarred bool
+}
+
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.SortBy,
+		arg.UserID,
+		arg.FeedUrl,
+		arg.Tag,
+		arg.IsRead,
+		arg.Since,
+		arg.Until,
+		arg.CursorAt,
+		arg.CursorID,
+		arg.MaxPosts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsForUserRow
+	for rows.Next() {
+		var i GetPostsForUserRow
+		if err := rows.Scan(
+			&i.Post.ID,
+			&i.Post.CreatedAt,
+			&i.Post.UpdatedAt,
+			&i.Post.Title,
+			&i.Post.Url,
+			&i.Post.Description,
+			&i.Post.PublishedAt,
+			&i.Post.FeedID,
+			&i.Post.Content,
+			&i.Post.SearchVector,
+			&i.FeedName,
+			&i.IsRead,
+			&i.IsStarred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPrunablePosts = `-- name: GetPrunablePosts :many
+SELECT candidates.id, feeds.name AS feed_name, feeds.url AS feed_url FROM (
+    SELECT
+        posts.id,
+        posts.feed_id,
+        COALESCE(posts.published_at, posts.created_at) AS posted_at,
+        ROW_NUMBER() OVER (
+            PARTITION BY posts.feed_id
+            ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC
+        ) AS position
+    FROM posts
+    WHERE NOT EXISTS (
+        SELECT 1 FROM saved_posts WHERE saved_posts.post_id = posts.id
+    )
+) AS candidates
+INNER JOIN feeds ON candidates.feed_id = feeds.id
+LEFT JOIN feed_follows ON candidates.feed_id = feed_follows.feed_id
+GROUP BY candidates.id, feeds.name, feeds.url, candidates.posted_at
+HAVING bool_and(
+    COALESCE(
+        candidates.posted_at < $1::timestamptz - INTERVAL '1 second' * NULLIF(COALESCE(
+            feed_follows.retention_max_age_seconds,
+            $2::bigint
+        ), 0),
+        false
+    )
+    OR COALESCE(
+        candidates.position > NULLIF(COALESCE(
+            feed_follows.retention_max_posts,
+            $3::integer
+        ), 0),
+        false
+    )
+)
+ORDER BY feeds.name, candidates.posted_at
+`
+
+type GetPrunablePostsParams struct {
+	Now                  time.Time
+	DefaultMaxAgeSeconds sql.NullInt64
+	DefaultMaxPosts      sql.NullInt32
+}
+
+type GetPrunablePostsRow struct {
+	ID       uuid.UUID
+	FeedName string
+	FeedUrl  string
+}
+
+// Feeds nobody follows get a single row with the global policy.
+func (q *Queries) GetPrunablePosts(ctx context.Context, arg GetPrunablePostsParams) ([]GetPrunablePostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPrunablePosts, arg.Now, arg.DefaultMaxAgeSeconds, arg.DefaultMaxPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPrunablePostsRow
+	for rows.Next() {
+		var i GetPrunablePostsRow
+		if err := rows.Scan(&i.ID, &i.FeedName, &i.FeedUrl); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    COALESCE(feed_follows.display_name, feeds.name)::text AS feed_name,
+    ts_rank(
+        posts.search_vector,
+        websearch_to_tsquery('english', $1)
+    )::real AS rank,
+    ts_headline(
+        'english',
+        coalesce(posts.description, '') || ' ' || coalesce(posts.content, ''),
+        websearch_to_tsquery('english', $1),
+        'StartSel=**, StopSel=**, MaxWords=30, MinWords=10, MaxFragments=2'
+    )::text AS snippet
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE
+    feed_follows.user_id = $2
+    AND posts.search_vector @@ websearch_to_tsquery('english', $1)
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT $3
+`
+
+type SearchPostsForUserParams struct {
+	Query    string
+	UserID   uuid.UUID
+	MaxPosts int32
+}
+
+type SearchPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt sql.NullTime
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser, arg.Query, arg.UserID, arg.MaxPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,69 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+type Querier interface {
+	ClearFollowedFeedsOrphanedAt(ctx context.Context) (int64, error)
+	CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error)
+	CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (CreateFeedFollowRow, error)
+	CreatePost(ctx context.Context, arg CreatePostParams) (Post, error)
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DeleteFeed(ctx context.Context, id uuid.UUID) error
+	DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error
+	// Feeds with starred posts are kept, since deleting the feed would delete the
+	// posts along with it.
+	DeleteFeedsOrphanedBefore(ctx context.Context, orphanedAt sql.NullTime) (int64, error)
+	DeletePosts(ctx context.Context, ids []uuid.UUID) (int64, error)
+	DeleteUnusedTags(ctx context.Context, userID uuid.UUID) error
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	GetFeedByUrl(ctx context.Context, url string) (Feed, error)
+	GetFeedFollow(ctx context.Context, arg GetFeedFollowParams) (FeedFollow, error)
+	GetFeedFollowByUrl(ctx context.Context, arg GetFeedFollowByUrlParams) (FeedFollow, error)
+	GetFeedFollowRetentions(ctx context.Context, feedID uuid.UUID) ([]GetFeedFollowRetentionsRow, error)
+	GetFeedFollowsForUser(ctx context.Context, arg GetFeedFollowsForUserParams) ([]GetFeedFollowsForUserRow, error)
+	GetFeeds(ctx context.Context) ([]GetFeedsRow, error)
+	GetFeedsOrphanedBefore(ctx context.Context, orphanedAt sql.NullTime) ([]GetFeedsOrphanedBeforeRow, error)
+	GetFeedsOwnedByUser(ctx context.Context, userID uuid.NullUUID) ([]Feed, error)
+	GetNextFeedFollower(ctx context.Context, arg GetNextFeedFollowerParams) (uuid.UUID, error)
+	GetNextFeedToFetch(ctx context.Context) (Feed, error)
+	GetPostByUrl(ctx context.Context, url string) (Post, error)
+	GetPostsByIDPrefix(ctx context.Context, arg GetPostsByIDPrefixParams) ([]Post, error)
+	GetPostsForFeed(ctx context.Context, arg GetPostsForFeedParams) ([]GetPostsForFeedRow, error)
+	GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error)
+	// Feeds nobody follows get a single row with the global policy.
+	GetPrunablePosts(ctx context.Context, arg GetPrunablePostsParams) ([]GetPrunablePostsRow, error)
+	GetStarredPostsForUser(ctx context.Context, userID uuid.UUID) ([]GetStarredPostsForUserRow, error)
+	GetTagsForUser(ctx context.Context, userID uuid.UUID) ([]GetTagsForUserRow, error)
+	GetUser(ctx context.Context, name string) (User, error)
+	GetUsers(ctx context.Context) ([]User, error)
+	MarkAllPostsRead(ctx context.Context, arg MarkAllPostsReadParams) (int64, error)
+	MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) (Feed, error)
+	MarkFeedPostsRead(ctx context.Context, arg MarkFeedPostsReadParams) (int64, error)
+	MarkOrphanedFeeds(ctx context.Context, orphanedAt sql.NullTime) (int64, error)
+	MarkPostRead(ctx context.Context, arg MarkPostReadParams) error
+	RenameFeed(ctx context.Context, arg RenameFeedParams) (Feed, error)
+	ResetFeeds(ctx context.Context) error
+	ResetUsers(ctx context.Context) error
+	SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error)
+	SetFeedFollowDisplayName(ctx context.Context, arg SetFeedFollowDisplayNameParams) (int64, error)
+	SetFeedFollowRetention(ctx context.Context, arg SetFeedFollowRetentionParams) (FeedFollow, error)
+	SetFeedOwner(ctx context.Context, arg SetFeedOwnerParams) error
+	SetFeedUrl(ctx context.Context, arg SetFeedUrlParams) (Feed, error)
+	StarPost(ctx context.Context, arg StarPostParams) error
+	TagFeedFollow(ctx context.Context, arg TagFeedFollowParams) error
+	UnstarPost(ctx context.Context, arg UnstarPostParams) (int64, error)
+	UntagFeedFollow(ctx context.Context, arg UntagFeedFollowParams) (int64, error)
+	UpdateFeedMetadata(ctx context.Context, arg UpdateFeedMetadataParams) (Feed, error)
+	UpsertTag(ctx context.Context, arg UpsertTagParams) (Tag, error)
+}
+
+var _ Querier = (*Queries)(nil)
@@ -0,0 +1,106 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: saved_posts.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getStarredPostsForUser = `-- name: GetStarredPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.content, posts.search_vector,
+    COALESCE(feed_follows.display_name, feeds.name)::text AS feed_name
+FROM posts
+INNER JOIN saved_posts ON posts.id = saved_posts.post_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN feed_follows
+    ON feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = saved_posts.user_id
+WHERE saved_posts.user_id = $1
+ORDER BY saved_posts.saved_at DESC
+`
+
+type GetStarredPostsForUserRow struct {
+	Post     Post
+	FeedName string
+}
+
+func (q *Queries) GetStarredPostsForUser(ctx context.Context, userID uuid.UUID) ([]GetStarredPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getStarredPostsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStarredPostsForUserRow
+	for rows.Next() {
+		var i GetStarredPostsForUserRow
+		if err := rows.Scan(
+			&i.Post.ID,
+			&i.Post.CreatedAt,
+			&i.Post.UpdatedAt,
+			&i.Post.Title,
+			&i.Post.Url,
+			&i.Post.Description,
+			&i.Post.PublishedAt,
+			&i.Post.FeedID,
+			&i.Post.Content,
+			&i.Post.SearchVector,
+			&i.FeedName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const starPost = `-- name: StarPost :exec
+INSERT INTO saved_posts (user_id, post_id, saved_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type StarPostParams struct {
+	UserID  uuid.UUID
+	PostID  uuid.UUID
+	SavedAt time.Time
+}
+
+func (q *Queries) StarPost(ctx context.Context, arg StarPostParams) error {
+	_, err := q.db.ExecContext(ctx, starPost, arg.UserID, arg.PostID, arg.SavedAt)
+	return err
+}
+
+const unstarPost = `-- name: UnstarPost :execrows
+DELETE FROM saved_posts
+WHERE user_id = $1 AND post_id = $2
+`
+
+type UnstarPostParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) UnstarPost(ctx context.Context, arg UnstarPostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unstarPost, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
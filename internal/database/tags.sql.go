@@ -0,0 +1,147 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: tags.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteUnusedTags = `-- name: DeleteUnusedTags :exec
+DELETE FROM tags
+WHERE
+    tags.user_id = $1
+    AND NOT EXISTS (
+        SELECT 1 FROM feed_follow_tags
+        WHERE feed_follow_tags.tag_id = tags.id
+    )
+`
+
+func (q *Queries) DeleteUnusedTags(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUnusedTags, userID)
+	return err
+}
+
+const getTagsForUser = `-- name: GetTagsForUser :many
+SELECT tags.name, COUNT(feed_follow_tags.feed_follow_id) AS feed_count FROM tags
+LEFT JOIN feed_follow_tags ON tags.id = feed_follow_tags.tag_id
+WHERE tags.user_id = $1
+GROUP BY tags.id
+ORDER BY tags.name
+`
+
+type GetTagsForUserRow struct {
+	Name      string
+	FeedCount int64
+}
+
+func (q *Queries) GetTagsForUser(ctx context.Context, userID uuid.UUID) ([]GetTagsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTagsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTagsForUserRow
+	for rows.Next() {
+		var i GetTagsForUserRow
+		if err := rows.Scan(&i.Name, &i.FeedCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tagFeedFollow = `-- name: TagFeedFollow :exec
+INSERT INTO feed_follow_tags (feed_follow_id, tag_id)
+VALUES (
+    $1,
+    $2
+)
+ON CONFLICT (feed_follow_id, tag_id) DO NOTHING
+`
+
+type TagFeedFollowParams struct {
+	FeedFollowID uuid.UUID
+	TagID        uuid.UUID
+}
+
+func (q *Queries) TagFeedFollow(ctx context.Context, arg TagFeedFollowParams) error {
+	_, err := q.db.ExecContext(ctx, tagFeedFollow, arg.FeedFollowID, arg.TagID)
+	return err
+}
+
+const untagFeedFollow = `-- name: UntagFeedFollow :execrows
+DELETE FROM feed_follow_tags
+WHERE
+    feed_follow_tags.feed_follow_id = $1
+    AND feed_follow_tags.tag_id = (
+        SELECT id FROM tags WHERE user_id = $2 AND name = $3
+    )
+`
+
+type UntagFeedFollowParams struct {
+	FeedFollowID uuid.UUID
+	UserID       uuid.UUID
+	Name         string
+}
+
+func (q *Queries) UntagFeedFollow(ctx context.Context, arg UntagFeedFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, untagFeedFollow, arg.FeedFollowID, arg.UserID, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertTag = `-- name: UpsertTag :one
+INSERT INTO tags (id, created_at, updated_at, user_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, name) DO UPDATE
+SET updated_at = EXCLUDED.updated_at
+RETURNING id, created_at, updated_at, user_id, name
+`
+
+type UpsertTagParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
+func (q *Queries) UpsertTag(ctx context.Context, arg UpsertTagParams) (Tag, error) {
+	row := q.db.QueryRowContext(ctx, upsertTag,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Name,
+	)
+	var i Tag
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+	)
+	return i, err
+}
@@ -0,0 +1,1556 @@
+// Package memory implements database.Querier in memory, so commands can be
+// tested without a database server.
+//
+// The store follows the PostgreSQL schema: unique and foreign key violations
+// return the same *pq.Error codes, lookups that find nothing return
+// sql.ErrNoRows, and deletes cascade the way the foreign keys do. Full-text
+// search is approximated with case-insensitive word matching.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/thihxm/gator/internal/database"
+)
+
+// Store holds every table in slices, in insertion order.
+type Store struct {
+	mu sync.Mutex
+	tables
+}
+
+type tables struct {
+	users       []database.User
+	feeds       []database.Feed
+	feedFollows []database.FeedFollow
+	posts       []database.Post
+	postReads   []database.PostRead
+	savedPosts  []database.SavedPost
+	tags        []database.Tag
+	followTags  []database.FeedFollowTag
+}
+
+var _ database.Querier = (*Store)(nil)
+
+func New() *Store {
+	return &Store{}
+}
+
+func (t tables) clone() tables {
+	return tables{
+		users:       slices.Clone(t.users),
+		feeds:       slices.Clone(t.feeds),
+		feedFollows: slices.Clone(t.feedFollows),
+		posts:       slices.Clone(t.posts),
+		postReads:   slices.Clone(t.postReads),
+		savedPosts:  slices.Clone(t.savedPosts),
+		tags:        slices.Clone(t.tags),
+		followTags:  slices.Clone(t.followTags),
+	}
+}
+
+// Transaction runs fn against the store and undoes all of its changes if it
+// returns an error. Unlike a database transaction, it doesn't isolate fn from
+// other callers.
+func (s *Store) Transaction(ctx context.Context, fn func(database.Querier) error) error {
+	s.mu.Lock()
+	snapshot := s.tables.clone()
+	s.mu.Unlock()
+
+	if err := fn(s); err != nil {
+		s.mu.Lock()
+		s.tables = snapshot
+		s.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func uniqueViolation(constraint string) error {
+	return &pq.Error{
+		Code:       "23505",
+		Message:    fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		Constraint: constraint,
+	}
+}
+
+func foreignKeyViolation(constraint string) error {
+	return &pq.Error{
+		Code:       "23503",
+		Message:    fmt.Sprintf("insert or update violates foreign key constraint %q", constraint),
+		Constraint: constraint,
+	}
+}
+
+// timestamp rounds t to the microsecond precision PostgreSQL stores.
+func timestamp(t time.Time) time.Time {
+	return t.Round(time.Microsecond)
+}
+
+func nullTimestamp(t sql.NullTime) sql.NullTime {
+	if t.Valid {
+		t.Time = timestamp(t.Time)
+	}
+	return t
+}
+
+func compareUUIDs(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+// Lookups and cascades. Callers must hold s.mu.
+
+func (s *Store) user(id uuid.UUID) (int, bool) {
+	for i, user := range s.users {
+		if user.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (s *Store) feed(id uuid.UUID) (int, bool) {
+	for i, feed := range s.feeds {
+		if feed.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (s *Store) feedByURL(url string) (int, bool) {
+	for i, feed := range s.feeds {
+		if feed.Url == url {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (s *Store) feedFollow(userID, feedID uuid.UUID) (int, bool) {
+	for i, follow := range s.feedFollows {
+		if follow.UserID == userID && follow.FeedID == feedID {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (s *Store) post(id uuid.UUID) (int, bool) {
+	for i, post := range s.posts {
+		if post.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (s *Store) tag(userID uuid.UUID, name string) (int, bool) {
+	for i, tag := range s.tags {
+		if tag.UserID == userID && tag.Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (s *Store) isFollowing(userID, feedID uuid.UUID) bool {
+	_, ok := s.feedFollow(userID, feedID)
+	return ok
+}
+
+func (s *Store) hasFollowers(feedID uuid.UUID) bool {
+	for _, follow := range s.feedFollows {
+		if follow.FeedID == feedID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) isRead(userID, postID uuid.UUID) bool {
+	for _, read := range s.postReads {
+		if read.UserID == userID && read.PostID == postID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) isSaved(postID uuid.UUID) bool {
+	for _, saved := range s.savedPosts {
+		if saved.PostID == postID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) isSavedBy(userID, postID uuid.UUID) bool {
+	for _, saved := range s.savedPosts {
+		if saved.UserID == userID && saved.PostID == postID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) hasTag(feedFollowID uuid.UUID, name string) bool {
+	for _, followTag := range s.followTags {
+		if followTag.FeedFollowID != feedFollowID {
+			continue
+		}
+		for _, tag := range s.tags {
+			if tag.ID == followTag.TagID && tag.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Store) tagNames(feedFollowID uuid.UUID) []string {
+	names := []string{}
+	for _, followTag := range s.followTags {
+		if followTag.FeedFollowID != feedFollowID {
+			continue
+		}
+		for _, tag := range s.tags {
+			if tag.ID == followTag.TagID {
+				names = append(names, tag.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// feedName is the name user sees for feed: their own title for it if they
+// set one, or the feed's name.
+func (s *Store) feedName(userID uuid.UUID, feed database.Feed) string {
+	if i, ok := s.feedFollow(userID, feed.ID); ok && s.feedFollows[i].DisplayName.Valid {
+		return s.feedFollows[i].DisplayName.String
+	}
+	return feed.Name
+}
+
+func (s *Store) deleteUsers(match func(database.User) bool) {
+	var kept []database.User
+	for _, user := range s.users {
+		if !match(user) {
+			kept = append(kept, user)
+			continue
+		}
+
+		for i := range s.feeds {
+			if s.feeds[i].UserID.Valid && s.feeds[i].UserID.UUID == user.ID {
+				s.feeds[i].UserID = uuid.NullUUID{}
+			}
+		}
+		s.deleteFeedFollows(func(follow database.FeedFollow) bool { return follow.UserID == user.ID })
+		s.deleteTags(func(tag database.Tag) bool { return tag.UserID == user.ID })
+		s.postReads = filter(s.postReads, func(read database.PostRead) bool { return read.UserID != user.ID })
+		s.savedPosts = filter(s.savedPosts, func(saved database.SavedPost) bool { return saved.UserID != user.ID })
+	}
+	s.users = kept
+}
+
+func (s *Store) deleteFeeds(match func(database.Feed) bool) int64 {
+	var kept []database.Feed
+	var count int64
+	for _, feed := range s.feeds {
+		if !match(feed) {
+			kept = append(kept, feed)
+			continue
+		}
+
+		count++
+		s.deleteFeedFollows(func(follow database.FeedFollow) bool { return follow.FeedID == feed.ID })
+		s.deletePosts(func(post database.Post) bool { return post.FeedID == feed.ID })
+	}
+	s.feeds = kept
+	return count
+}
+
+func (s *Store) deleteFeedFollows(match func(database.FeedFollow) bool) {
+	var kept []database.FeedFollow
+	for _, follow := range s.feedFollows {
+		if !match(follow) {
+			kept = append(kept, follow)
+			continue
+		}
+
+		s.followTags = filter(s.followTags, func(followTag database.FeedFollowTag) bool {
+			return followTag.FeedFollowID != follow.ID
+		})
+	}
+	s.feedFollows = kept
+}
+
+func (s *Store) deletePosts(match func(database.Post) bool) int64 {
+	var kept []database.Post
+	var count int64
+	for _, post := range s.posts {
+		if !match(post) {
+			kept = append(kept, post)
+			continue
+		}
+
+		count++
+		s.postReads = filter(s.postReads, func(read database.PostRead) bool { return read.PostID != post.ID })
+		s.savedPosts = filter(s.savedPosts, func(saved database.SavedPost) bool { return saved.PostID != post.ID })
+	}
+	s.posts = kept
+	return count
+}
+
+func (s *Store) deleteTags(match func(database.Tag) bool) {
+	var kept []database.Tag
+	for _, tag := range s.tags {
+		if !match(tag) {
+			kept = append(kept, tag)
+			continue
+		}
+
+		s.followTags = filter(s.followTags, func(followTag database.FeedFollowTag) bool {
+			return followTag.TagID != tag.ID
+		})
+	}
+	s.tags = kept
+}
+
+func filter[T any](rows []T, keep func(T) bool) []T {
+	var kept []T
+	for _, row := range rows {
+		if keep(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// Users
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Name == arg.Name {
+			return database.User{}, uniqueViolation("users_name_key")
+		}
+	}
+
+	user := database.User{
+		ID:        arg.ID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		UpdatedAt: timestamp(arg.UpdatedAt),
+		Name:      arg.Name,
+	}
+	s.users = append(s.users, user)
+
+	return user, nil
+}
+
+func (s *Store) GetUser(ctx context.Context, name string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUsers(ctx context.Context) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]database.User(nil), s.users...), nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteUsers(func(user database.User) bool { return user.ID == id })
+	return nil
+}
+
+func (s *Store) ResetUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteUsers(func(database.User) bool { return true })
+	return nil
+}
+
+// Feeds
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.feedByURL(arg.Url); ok {
+		return database.Feed{}, uniqueViolation("feeds_url_key")
+	}
+
+	if arg.UserID.Valid {
+		if _, ok := s.user(arg.UserID.UUID); !ok {
+			return database.Feed{}, foreignKeyViolation("feeds_user_id_fkey")
+		}
+	}
+
+	feed := database.Feed{
+		ID:        arg.ID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		UpdatedAt: timestamp(arg.UpdatedAt),
+		Name:      arg.Name,
+		Url:       arg.Url,
+		UserID:    arg.UserID,
+	}
+	s.feeds = append(s.feeds, feed)
+
+	return feed, nil
+}
+
+func (s *Store) GetFeeds(ctx context.Context) ([]database.GetFeedsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFeedsRow
+	for _, feed := range s.feeds {
+		row := database.GetFeedsRow{
+			ID:            feed.ID,
+			CreatedAt:     feed.CreatedAt,
+			UpdatedAt:     feed.UpdatedAt,
+			Name:          feed.Name,
+			Url:           feed.Url,
+			UserID:        feed.UserID,
+			LastFetchedAt: feed.LastFetchedAt,
+			SiteLink:      feed.SiteLink,
+			Description:   feed.Description,
+			Language:      feed.Language,
+			ImageUrl:      feed.ImageUrl,
+			Generator:     feed.Generator,
+			OrphanedAt:    feed.OrphanedAt,
+		}
+		if feed.UserID.Valid {
+			if i, ok := s.user(feed.UserID.UUID); ok {
+				row.UserName = sql.NullString{String: s.users[i].Name, Valid: true}
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func (s *Store) GetFeedsOwnedByUser(ctx context.Context, userID uuid.NullUUID) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return filter(s.feeds, func(feed database.Feed) bool {
+		return userID.Valid && feed.UserID.Valid && feed.UserID.UUID == userID.UUID
+	}), nil
+}
+
+func (s *Store) GetFeedByUrl(ctx context.Context, url string) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.feedByURL(url)
+	if !ok {
+		return database.Feed{}, sql.ErrNoRows
+	}
+
+	return s.feeds[i], nil
+}
+
+// updateFeed applies update to the feed with the given ID and returns the
+// updated feed.
+func (s *Store) updateFeed(id uuid.UUID, update func(feed *database.Feed) error) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.feed(id)
+	if !ok {
+		return database.Feed{}, sql.ErrNoRows
+	}
+
+	feed := s.feeds[i]
+	if err := update(&feed); err != nil {
+		return database.Feed{}, err
+	}
+	s.feeds[i] = feed
+
+	return feed, nil
+}
+
+func (s *Store) MarkFeedFetched(ctx context.Context, arg database.MarkFeedFetchedParams) (database.Feed, error) {
+	return s.updateFeed(arg.ID, func(feed *database.Feed) error {
+		feed.LastFetchedAt = nullTimestamp(arg.LastFetchedAt)
+		feed.UpdatedAt = timestamp(arg.UpdatedAt)
+		return nil
+	})
+}
+
+func (s *Store) UpdateFeedMetadata(ctx context.Context, arg database.UpdateFeedMetadataParams) (database.Feed, error) {
+	return s.updateFeed(arg.ID, func(feed *database.Feed) error {
+		feed.SiteLink = arg.SiteLink
+		feed.Description = arg.Description
+		feed.Language = arg.Language
+		feed.ImageUrl = arg.ImageUrl
+		feed.Generator = arg.Generator
+		feed.UpdatedAt = timestamp(arg.UpdatedAt)
+		return nil
+	})
+}
+
+func (s *Store) RenameFeed(ctx context.Context, arg database.RenameFeedParams) (database.Feed, error) {
+	return s.updateFeed(arg.ID, func(feed *database.Feed) error {
+		feed.Name = arg.Name
+		feed.UpdatedAt = timestamp(arg.UpdatedAt)
+		return nil
+	})
+}
+
+func (s *Store) SetFeedUrl(ctx context.Context, arg database.SetFeedUrlParams) (database.Feed, error) {
+	return s.updateFeed(arg.ID, func(feed *database.Feed) error {
+		if i, ok := s.feedByURL(arg.Url); ok && s.feeds[i].ID != feed.ID {
+			return uniqueViolation("feeds_url_key")
+		}
+
+		feed.Url = arg.Url
+		feed.LastFetchedAt = sql.NullTime{}
+		feed.UpdatedAt = timestamp(arg.UpdatedAt)
+		return nil
+	})
+}
+
+func (s *Store) SetFeedOwner(ctx context.Context, arg database.SetFeedOwnerParams) error {
+	_, err := s.updateFeed(arg.ID, func(feed *database.Feed) error {
+		if arg.UserID.Valid {
+			if _, ok := s.user(arg.UserID.UUID); !ok {
+				return foreignKeyViolation("feeds_user_id_fkey")
+			}
+		}
+
+		feed.UserID = arg.UserID
+		feed.UpdatedAt = timestamp(arg.UpdatedAt)
+		return nil
+	})
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteFeeds(func(feed database.Feed) bool { return feed.ID == id })
+	return nil
+}
+
+func (s *Store) ResetFeeds(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteFeeds(func(database.Feed) bool { return true })
+	return nil
+}
+
+func (s *Store) GetNextFeedToFetch(ctx context.Context) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *database.Feed
+	for i, feed := range s.feeds {
+		if !s.hasFollowers(feed.ID) {
+			continue
+		}
+
+		if !feed.LastFetchedAt.Valid {
+			return feed, nil
+		}
+
+		if next == nil || feed.LastFetchedAt.Time.Before(next.LastFetchedAt.Time) {
+			next = &s.feeds[i]
+		}
+	}
+
+	if next == nil {
+		return database.Feed{}, sql.ErrNoRows
+	}
+
+	return *next, nil
+}
+
+func (s *Store) MarkOrphanedFeeds(ctx context.Context, orphanedAt sql.NullTime) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for i, feed := range s.feeds {
+		if !feed.OrphanedAt.Valid && !s.hasFollowers(feed.ID) {
+			s.feeds[i].OrphanedAt = nullTimestamp(orphanedAt)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *Store) ClearFollowedFeedsOrphanedAt(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for i, feed := range s.feeds {
+		if feed.OrphanedAt.Valid && s.hasFollowers(feed.ID) {
+			s.feeds[i].OrphanedAt = sql.NullTime{}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *Store) GetFeedsOrphanedBefore(ctx context.Context, orphanedAt sql.NullTime) ([]database.GetFeedsOrphanedBeforeRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFeedsOrphanedBeforeRow
+	for _, feed := range s.feeds {
+		if !s.orphanedBefore(feed, orphanedAt) {
+			continue
+		}
+
+		var postCount int64
+		for _, post := range s.posts {
+			if post.FeedID == feed.ID {
+				postCount++
+			}
+		}
+
+		rows = append(rows, database.GetFeedsOrphanedBeforeRow{
+			ID:            feed.ID,
+			CreatedAt:     feed.CreatedAt,
+			UpdatedAt:     feed.UpdatedAt,
+			Name:          feed.Name,
+			Url:           feed.Url,
+			UserID:        feed.UserID,
+			LastFetchedAt: feed.LastFetchedAt,
+			SiteLink:      feed.SiteLink,
+			Description:   feed.Description,
+			Language:      feed.Language,
+			ImageUrl:      feed.ImageUrl,
+			Generator:     feed.Generator,
+			OrphanedAt:    feed.OrphanedAt,
+			PostCount:     postCount,
+		})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].OrphanedAt.Time.Before(rows[j].OrphanedAt.Time)
+	})
+
+	return rows, nil
+}
+
+func (s *Store) DeleteFeedsOrphanedBefore(ctx context.Context, orphanedAt sql.NullTime) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteFeeds(func(feed database.Feed) bool {
+		return s.orphanedBefore(feed, orphanedAt)
+	}), nil
+}
+
+// orphanedBefore reports whether gc deletes feed, which it doesn't while the
+// feed has starred posts.
+func (s *Store) orphanedBefore(feed database.Feed, orphanedAt sql.NullTime) bool {
+	if !feed.OrphanedAt.Valid || !orphanedAt.Valid || !feed.OrphanedAt.Time.Before(orphanedAt.Time) {
+		return false
+	}
+
+	for _, post := range s.posts {
+		if post.FeedID == feed.ID && s.isSaved(post.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+// Feed follows
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isFollowing(arg.UserID, arg.FeedID) {
+		return database.CreateFeedFollowRow{}, uniqueViolation("uc_user_feed")
+	}
+
+	userIndex, ok := s.user(arg.UserID)
+	if !ok {
+		return database.CreateFeedFollowRow{}, foreignKeyViolation("feed_follows_user_id_fkey")
+	}
+
+	feedIndex, ok := s.feed(arg.FeedID)
+	if !ok {
+		return database.CreateFeedFollowRow{}, foreignKeyViolation("feed_follows_feed_id_fkey")
+	}
+
+	follow := database.FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		UpdatedAt: timestamp(arg.UpdatedAt),
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+	}
+	s.feedFollows = append(s.feedFollows, follow)
+
+	return database.CreateFeedFollowRow{
+		ID:                     follow.ID,
+		CreatedAt:              follow.CreatedAt,
+		UpdatedAt:              follow.UpdatedAt,
+		UserID:                 follow.UserID,
+		FeedID:                 follow.FeedID,
+		DisplayName:            follow.DisplayName,
+		RetentionMaxAgeSeconds: follow.RetentionMaxAgeSeconds,
+		RetentionMaxPosts:      follow.RetentionMaxPosts,
+		FeedName:               s.feeds[feedIndex].Name,
+		UserName:               s.users[userIndex].Name,
+	}, nil
+}
+
+func (s *Store) GetFeedFollowsForUser(ctx context.Context, arg database.GetFeedFollowsForUserParams) ([]database.GetFeedFollowsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFeedFollowsForUserRow
+	for _, follow := range s.feedFollows {
+		if follow.UserID != arg.UserID {
+			continue
+		}
+		if arg.Tag.Valid && !s.hasTag(follow.ID, arg.Tag.String) {
+			continue
+		}
+
+		feedIndex, _ := s.feed(follow.FeedID)
+		userIndex, _ := s.user(follow.UserID)
+		feed := s.feeds[feedIndex]
+
+		rows = append(rows, database.GetFeedFollowsForUserRow{
+			FeedName:     s.feedName(follow.UserID, feed),
+			FeedUrl:      feed.Url,
+			FeedSiteLink: feed.SiteLink,
+			UserName:     s.users[userIndex].Name,
+			Tags:         s.tagNames(follow.ID),
+		})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].FeedName < rows[j].FeedName
+	})
+
+	return rows, nil
+}
+
+func (s *Store) GetFeedFollow(ctx context.Context, arg database.GetFeedFollowParams) (database.FeedFollow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.feedFollow(arg.UserID, arg.FeedID)
+	if !ok {
+		return database.FeedFollow{}, sql.ErrNoRows
+	}
+
+	return s.feedFollows[i], nil
+}
+
+func (s *Store) GetFeedFollowByUrl(ctx context.Context, arg database.GetFeedFollowByUrlParams) (database.FeedFollow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feedIndex, ok := s.feedByURL(arg.Url)
+	if !ok {
+		return database.FeedFollow{}, sql.ErrNoRows
+	}
+
+	i, ok := s.feedFollow(arg.UserID, s.feeds[feedIndex].ID)
+	if !ok {
+		return database.FeedFollow{}, sql.ErrNoRows
+	}
+
+	return s.feedFollows[i], nil
+}
+
+func (s *Store) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feedIndex, ok := s.feedByURL(arg.Url)
+	if !ok {
+		return nil
+	}
+
+	feedID := s.feeds[feedIndex].ID
+	s.deleteFeedFollows(func(follow database.FeedFollow) bool {
+		return follow.UserID == arg.UserID && follow.FeedID == feedID
+	})
+
+	return nil
+}
+
+func (s *Store) GetNextFeedFollower(ctx context.Context, arg database.GetNextFeedFollowerParams) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *database.FeedFollow
+	for i, follow := range s.feedFollows {
+		if follow.FeedID != arg.FeedID || follow.UserID == arg.UserID {
+			continue
+		}
+
+		if next == nil || follow.CreatedAt.Before(next.CreatedAt) {
+			next = &s.feedFollows[i]
+		}
+	}
+
+	if next == nil {
+		return uuid.UUID{}, sql.ErrNoRows
+	}
+
+	return next.UserID, nil
+}
+
+func (s *Store) SetFeedFollowDisplayName(ctx context.Context, arg database.SetFeedFollowDisplayNameParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feedIndex, ok := s.feedByURL(arg.Url)
+	if !ok {
+		return 0, nil
+	}
+
+	i, ok := s.feedFollow(arg.UserID, s.feeds[feedIndex].ID)
+	if !ok {
+		return 0, nil
+	}
+
+	s.feedFollows[i].DisplayName = arg.DisplayName
+	s.feedFollows[i].UpdatedAt = timestamp(arg.UpdatedAt)
+
+	return 1, nil
+}
+
+func (s *Store) SetFeedFollowRetention(ctx context.Context, arg database.SetFeedFollowRetentionParams) (database.FeedFollow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, follow := range s.feedFollows {
+		if follow.ID != arg.ID {
+			continue
+		}
+
+		s.feedFollows[i].RetentionMaxAgeSeconds = arg.RetentionMaxAgeSeconds
+		s.feedFollows[i].RetentionMaxPosts = arg.RetentionMaxPosts
+		s.feedFollows[i].UpdatedAt = timestamp(arg.UpdatedAt)
+		return s.feedFollows[i], nil
+	}
+
+	return database.FeedFollow{}, sql.ErrNoRows
+}
+
+func (s *Store) GetFeedFollowRetentions(ctx context.Context, feedID uuid.UUID) ([]database.GetFeedFollowRetentionsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFeedFollowRetentionsRow
+	for _, follow := range s.feedFollows {
+		if follow.FeedID == feedID {
+			rows = append(rows, database.GetFeedFollowRetentionsRow{
+				RetentionMaxAgeSeconds: follow.RetentionMaxAgeSeconds,
+				RetentionMaxPosts:      follow.RetentionMaxPosts,
+			})
+		}
+	}
+
+	return rows, nil
+}
+
+// Posts
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, post := range s.posts {
+		if post.Url == arg.Url {
+			return database.Post{}, uniqueViolation("posts_url_key")
+		}
+	}
+
+	if _, ok := s.feed(arg.FeedID); !ok {
+		return database.Post{}, foreignKeyViolation("posts_feed_id_fkey")
+	}
+
+	post := database.Post{
+		ID:          arg.ID,
+		CreatedAt:   timestamp(arg.CreatedAt),
+		UpdatedAt:   timestamp(arg.UpdatedAt),
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: nullTimestamp(arg.PublishedAt),
+		FeedID:      arg.FeedID,
+		Content:     arg.Content,
+	}
+	s.posts = append(s.posts, post)
+
+	return post, nil
+}
+
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type sortedRow struct {
+		row    database.GetPostsForUserRow
+		sortAt time.Time
+	}
+
+	var rows []sortedRow
+	for _, follow := range s.feedFollows {
+		if follow.UserID != arg.UserID {
+			continue
+		}
+
+		feedIndex, _ := s.feed(follow.FeedID)
+		feed := s.feeds[feedIndex]
+		if arg.FeedUrl.Valid && feed.Url != arg.FeedUrl.String {
+			continue
+		}
+		if arg.Tag.Valid && !s.hasTag(follow.ID, arg.Tag.String) {
+			continue
+		}
+
+		for _, post := range s.posts {
+			if post.FeedID != feed.ID {
+				continue
+			}
+			if arg.IsRead.Valid && s.isRead(arg.UserID, post.ID) != arg.IsRead.Bool {
+				continue
+			}
+
+			sortAt := post.CreatedAt
+			if arg.SortBy == "published" && post.PublishedAt.Valid {
+				sortAt = post.PublishedAt.Time
+			}
+
+			if arg.Since.Valid && sortAt.Before(arg.Since.Time) {
+				continue
+			}
+			if arg.Until.Valid && !sortAt.Before(arg.Until.Time) {
+				continue
+			}
+			if arg.CursorAt.Valid {
+				if sortAt.After(arg.CursorAt.Time) {
+					continue
+				}
+				if sortAt.Equal(arg.CursorAt.Time) && compareUUIDs(post.ID, arg.CursorID.UUID) >= 0 {
+					continue
+				}
+			}
+
+			rows = append(rows, sortedRow{
+				row: database.GetPostsForUserRow{
+					Post:      post,
+					FeedName:  s.feedName(arg.UserID, feed),
+					IsRead:    s.isRead(arg.UserID, post.ID),
+					IsStarred: s.isSavedBy(arg.UserID, post.ID),
+				},
+				sortAt: sortAt,
+			})
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].sortAt.Equal(rows[j].sortAt) {
+			return rows[i].sortAt.After(rows[j].sortAt)
+		}
+		return compareUUIDs(rows[i].row.Post.ID, rows[j].row.Post.ID) > 0
+	})
+
+	var result []database.GetPostsForUserRow
+	for _, row := range rows {
+		if len(result) == int(arg.MaxPosts) {
+			break
+		}
+		result = append(result, row.row)
+	}
+
+	return result, nil
+}
+
+func (s *Store) GetPostsForFeed(ctx context.Context, arg database.GetPostsForFeedParams) ([]database.GetPostsForFeedRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feedIndex, ok := s.feedByURL(arg.Url)
+	if !ok {
+		return nil, nil
+	}
+	feed := s.feeds[feedIndex]
+
+	posts := filter(s.posts, func(post database.Post) bool { return post.FeedID == feed.ID })
+	sort.SliceStable(posts, func(i, j int) bool {
+		a, b := posts[i], posts[j]
+		if a.PublishedAt.Valid != b.PublishedAt.Valid {
+			return a.PublishedAt.Valid
+		}
+		if !a.PublishedAt.Time.Equal(b.PublishedAt.Time) {
+			return a.PublishedAt.Time.After(b.PublishedAt.Time)
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+
+	var rows []database.GetPostsForFeedRow
+	for _, post := range posts {
+		if len(rows) == int(arg.Limit) {
+			break
+		}
+		rows = append(rows, database.GetPostsForFeedRow{Post: post, FeedName: feed.Name})
+	}
+
+	return rows, nil
+}
+
+func (s *Store) GetPostByUrl(ctx context.Context, url string) (database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, post := range s.posts {
+		if post.Url == url {
+			return post, nil
+		}
+	}
+
+	return database.Post{}, sql.ErrNoRows
+}
+
+func (s *Store) GetPostsByIDPrefix(ctx context.Context, arg database.GetPostsByIDPrefixParams) ([]database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var posts []database.Post
+	for _, post := range s.posts {
+		if len(posts) == 2 {
+			break
+		}
+		if !strings.HasPrefix(post.ID.String(), arg.Prefix) {
+			continue
+		}
+		if s.isFollowing(arg.UserID, post.FeedID) || s.isSavedBy(arg.UserID, post.ID) {
+			posts = append(posts, post)
+		}
+	}
+
+	return posts, nil
+}
+
+func (s *Store) SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	terms := searchTerms(arg.Query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var rows []database.SearchPostsForUserRow
+	for _, follow := range s.feedFollows {
+		if follow.UserID != arg.UserID {
+			continue
+		}
+
+		feedIndex, _ := s.feed(follow.FeedID)
+		feed := s.feeds[feedIndex]
+
+		for _, post := range s.posts {
+			if post.FeedID != feed.ID {
+				continue
+			}
+
+			body := strings.TrimSpace(post.Description.String + " " + post.Content.String)
+			rank, ok := searchRank(terms, post.Title, body)
+			if !ok {
+				continue
+			}
+
+			rows = append(rows, database.SearchPostsForUserRow{
+				ID:          post.ID,
+				Title:       post.Title,
+				Url:         post.Url,
+				PublishedAt: post.PublishedAt,
+				FeedName:    s.feedName(arg.UserID, feed),
+				Rank:        rank,
+				Snippet:     searchSnippet(terms, body),
+			})
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.PublishedAt.Valid != b.PublishedAt.Valid {
+			return a.PublishedAt.Valid
+		}
+		return a.PublishedAt.Time.After(b.PublishedAt.Time)
+	})
+
+	if len(rows) > int(arg.MaxPosts) {
+		rows = rows[:arg.MaxPosts]
+	}
+
+	return rows, nil
+}
+
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.Trim(word, `"'()`)
+		if word != "" && word != "or" && word != "and" {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// searchRank reports whether every term appears in the title or body, and
+// ranks title matches above body matches.
+func searchRank(terms []string, title, body string) (float32, bool) {
+	title = strings.ToLower(title)
+	body = strings.ToLower(body)
+
+	var rank float32
+	for _, term := range terms {
+		if negated, ok := strings.CutPrefix(term, "-"); ok {
+			if strings.Contains(title, negated) || strings.Contains(body, negated) {
+				return 0, false
+			}
+			continue
+		}
+
+		matches := 2*strings.Count(title, term) + strings.Count(body, term)
+		if matches == 0 {
+			return 0, false
+		}
+		rank += float32(matches)
+	}
+
+	return rank, true
+}
+
+// searchSnippet wraps the words of body that match a term in ** markers,
+// like the ts_headline call of the PostgreSQL query.
+func searchSnippet(terms []string, body string) string {
+	words := strings.Fields(body)
+	if len(words) > 30 {
+		words = words[:30]
+	}
+
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		for _, term := range terms {
+			if !strings.HasPrefix(term, "-") && strings.Contains(lower, term) {
+				words[i] = "**" + word + "**"
+				break
+			}
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+func (s *Store) GetPrunablePosts(ctx context.Context, arg database.GetPrunablePostsParams) ([]database.GetPrunablePostsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type candidate struct {
+		row      database.GetPrunablePostsRow
+		postedAt time.Time
+	}
+
+	var candidates []candidate
+	for _, feed := range s.feeds {
+		posts := filter(s.posts, func(post database.Post) bool {
+			return post.FeedID == feed.ID && !s.isSaved(post.ID)
+		})
+		sort.Slice(posts, func(i, j int) bool {
+			a, b := postedAt(posts[i]), postedAt(posts[j])
+			if !a.Equal(b) {
+				return a.After(b)
+			}
+			return compareUUIDs(posts[i].ID, posts[j].ID) > 0
+		})
+
+		// Posts are kept as long as one follower's policy keeps them, and
+		// feeds nobody follows use the global policy.
+		type policy struct {
+			maxAge   sql.NullInt64
+			maxPosts sql.NullInt32
+		}
+		policies := []policy{{arg.DefaultMaxAgeSeconds, arg.DefaultMaxPosts}}
+		if s.hasFollowers(feed.ID) {
+			policies = nil
+		}
+		for _, follow := range s.feedFollows {
+			if follow.FeedID != feed.ID {
+				continue
+			}
+
+			p := policy{arg.DefaultMaxAgeSeconds, arg.DefaultMaxPosts}
+			if follow.RetentionMaxAgeSeconds.Valid {
+				p.maxAge = follow.RetentionMaxAgeSeconds
+			}
+			if follow.RetentionMaxPosts.Valid {
+				p.maxPosts = follow.RetentionMaxPosts
+			}
+			policies = append(policies, p)
+		}
+
+		for i, post := range posts {
+			kept := false
+			for _, p := range policies {
+				tooOld := p.maxAge.Valid && p.maxAge.Int64 > 0 &&
+					postedAt(post).Before(arg.Now.Add(-time.Duration(p.maxAge.Int64)*time.Second))
+				tooMany := p.maxPosts.Valid && p.maxPosts.Int32 > 0 && i+1 > int(p.maxPosts.Int32)
+				if !tooOld && !tooMany {
+					kept = true
+					break
+				}
+			}
+			if kept {
+				continue
+			}
+
+			candidates = append(candidates, candidate{
+				row: database.GetPrunablePostsRow{
+					ID:       post.ID,
+					FeedName: feed.Name,
+					FeedUrl:  feed.Url,
+				},
+				postedAt: postedAt(post),
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.row.FeedName != b.row.FeedName {
+			return a.row.FeedName < b.row.FeedName
+		}
+		return a.postedAt.Before(b.postedAt)
+	})
+
+	var rows []database.GetPrunablePostsRow
+	for _, candidate := range candidates {
+		rows = append(rows, candidate.row)
+	}
+
+	return rows, nil
+}
+
+func postedAt(post database.Post) time.Time {
+	if post.PublishedAt.Valid {
+		return post.PublishedAt.Time
+	}
+	return post.CreatedAt
+}
+
+func (s *Store) DeletePosts(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deletePosts(func(post database.Post) bool {
+		for _, id := range ids {
+			if post.ID == id {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// Post reads
+
+func (s *Store) markRead(userID, postID uuid.UUID, readAt time.Time) (int64, error) {
+	if s.isRead(userID, postID) {
+		return 0, nil
+	}
+
+	if _, ok := s.user(userID); !ok {
+		return 0, foreignKeyViolation("post_reads_user_id_fkey")
+	}
+	if _, ok := s.post(postID); !ok {
+		return 0, foreignKeyViolation("post_reads_post_id_fkey")
+	}
+
+	s.postReads = append(s.postReads, database.PostRead{
+		UserID: userID,
+		PostID: postID,
+		ReadAt: timestamp(readAt),
+	})
+
+	return 1, nil
+}
+
+func (s *Store) MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.markRead(arg.UserID, arg.PostID, arg.ReadAt)
+	return err
+}
+
+func (s *Store) MarkAllPostsRead(ctx context.Context, arg database.MarkAllPostsReadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, post := range s.posts {
+		if !s.isFollowing(arg.UserID, post.FeedID) {
+			continue
+		}
+
+		marked, err := s.markRead(arg.UserID, post.ID, arg.ReadAt)
+		if err != nil {
+			return 0, err
+		}
+		count += marked
+	}
+
+	return count, nil
+}
+
+func (s *Store) MarkFeedPostsRead(ctx context.Context, arg database.MarkFeedPostsReadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feedIndex, ok := s.feedByURL(arg.Url)
+	if !ok {
+		return 0, nil
+	}
+	feedID := s.feeds[feedIndex].ID
+
+	if !s.isFollowing(arg.UserID, feedID) {
+		return 0, nil
+	}
+
+	var count int64
+	for _, post := range s.posts {
+		if post.FeedID != feedID {
+			continue
+		}
+
+		marked, err := s.markRead(arg.UserID, post.ID, arg.ReadAt)
+		if err != nil {
+			return 0, err
+		}
+		count += marked
+	}
+
+	return count, nil
+}
+
+// Saved posts
+
+func (s *Store) StarPost(ctx context.Context, arg database.StarPostParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isSavedBy(arg.UserID, arg.PostID) {
+		return nil
+	}
+
+	if _, ok := s.user(arg.UserID); !ok {
+		return foreignKeyViolation("saved_posts_user_id_fkey")
+	}
+	if _, ok := s.post(arg.PostID); !ok {
+		return foreignKeyViolation("saved_posts_post_id_fkey")
+	}
+
+	s.savedPosts = append(s.savedPosts, database.SavedPost{
+		UserID:  arg.UserID,
+		PostID:  arg.PostID,
+		SavedAt: timestamp(arg.SavedAt),
+	})
+
+	return nil
+}
+
+func (s *Store) UnstarPost(ctx context.Context, arg database.UnstarPostParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.savedPosts)
+	s.savedPosts = filter(s.savedPosts, func(saved database.SavedPost) bool {
+		return saved.UserID != arg.UserID || saved.PostID != arg.PostID
+	})
+
+	return int64(before - len(s.savedPosts)), nil
+}
+
+func (s *Store) GetStarredPostsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetStarredPostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := filter(s.savedPosts, func(saved database.SavedPost) bool { return saved.UserID == userID })
+	sort.SliceStable(saved, func(i, j int) bool {
+		return saved[i].SavedAt.After(saved[j].SavedAt)
+	})
+
+	var rows []database.GetStarredPostsForUserRow
+	for _, saved := range saved {
+		postIndex, _ := s.post(saved.PostID)
+		post := s.posts[postIndex]
+		feedIndex, _ := s.feed(post.FeedID)
+
+		rows = append(rows, database.GetStarredPostsForUserRow{
+			Post:     post,
+			FeedName: s.feedName(userID, s.feeds[feedIndex]),
+		})
+	}
+
+	return rows, nil
+}
+
+// Tags
+
+func (s *Store) UpsertTag(ctx context.Context, arg database.UpsertTagParams) (database.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i, ok := s.tag(arg.UserID, arg.Name); ok {
+		s.tags[i].UpdatedAt = timestamp(arg.UpdatedAt)
+		return s.tags[i], nil
+	}
+
+	if _, ok := s.user(arg.UserID); !ok {
+		return database.Tag{}, foreignKeyViolation("tags_user_id_fkey")
+	}
+
+	tag := database.Tag{
+		ID:        arg.ID,
+		CreatedAt: timestamp(arg.CreatedAt),
+		UpdatedAt: timestamp(arg.UpdatedAt),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+	}
+	s.tags = append(s.tags, tag)
+
+	return tag, nil
+}
+
+func (s *Store) TagFeedFollow(ctx context.Context, arg database.TagFeedFollowParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, followTag := range s.followTags {
+		if followTag.FeedFollowID == arg.FeedFollowID && followTag.TagID == arg.TagID {
+			return nil
+		}
+	}
+
+	s.followTags = append(s.followTags, database.FeedFollowTag{
+		FeedFollowID: arg.FeedFollowID,
+		TagID:        arg.TagID,
+	})
+
+	return nil
+}
+
+func (s *Store) UntagFeedFollow(ctx context.Context, arg database.UntagFeedFollowParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tagIndex, ok := s.tag(arg.UserID, arg.Name)
+	if !ok {
+		return 0, nil
+	}
+	tagID := s.tags[tagIndex].ID
+
+	before := len(s.followTags)
+	s.followTags = filter(s.followTags, func(followTag database.FeedFollowTag) bool {
+		return followTag.FeedFollowID != arg.FeedFollowID || followTag.TagID != tagID
+	})
+
+	return int64(before - len(s.followTags)), nil
+}
+
+func (s *Store) DeleteUnusedTags(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteTags(func(tag database.Tag) bool {
+		if tag.UserID != userID {
+			return false
+		}
+		for _, followTag := range s.followTags {
+			if followTag.TagID == tag.ID {
+				return false
+			}
+		}
+		return true
+	})
+
+	return nil
+}
+
+func (s *Store) GetTagsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetTagsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetTagsForUserRow
+	for _, tag := range s.tags {
+		if tag.UserID != userID {
+			continue
+		}
+
+		var count int64
+		for _, followTag := range s.followTags {
+			if followTag.TagID == tag.ID {
+				count++
+			}
+		}
+
+		rows = append(rows, database.GetTagsForUserRow{Name: tag.Name, FeedCount: count})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Name < rows[j].Name
+	})
+
+	return rows, nil
+}
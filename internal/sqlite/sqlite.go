@@ -0,0 +1,315 @@
+// Package sqlite lets the sqlc-generated queries in internal/database run
+// against an SQLite database.
+//
+// Every query generated by sqlc starts with a "-- name: <Name>" comment. DB
+// uses that name to swap the PostgreSQL query for the SQLite query with the
+// same name, and converts the arguments to values SQLite can compare.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+	"github.com/thihxm/gator/internal/database"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// TimeFormat is how times are stored. Times are always stored in UTC with a
+// fixed number of fractional digits, so they can be compared as text.
+const TimeFormat = "2006-01-02 15:04:05.000000000-07:00"
+
+const namePrefix = "-- name: "
+
+// DB implements database.DBTX on top of an SQLite connection or transaction.
+type DB struct {
+	db      database.DBTX
+	queries map[string]string
+}
+
+// New wraps db so it runs the SQLite versions of the queries found in the
+// .sql files of queries.
+func New(db database.DBTX, queries fs.FS) (*DB, error) {
+	files, err := fs.Glob(queries, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make(map[string]string)
+	for _, file := range files {
+		contents, err := fs.ReadFile(queries, file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, query := range strings.Split(string(contents), namePrefix)[1:] {
+			name := queryName(namePrefix + query)
+			if name == "" {
+				return nil, fmt.Errorf("%s: query without a name", file)
+			}
+			if _, ok := parsed[name]; ok {
+				return nil, fmt.Errorf("%s: duplicate query %s", file, name)
+			}
+
+			parsed[name] = namePrefix + strings.TrimSpace(query)
+		}
+	}
+
+	return &DB{db: db, queries: parsed}, nil
+}
+
+// WithTx returns a DB that runs its queries inside tx.
+func (d *DB) WithTx(tx *sql.Tx) *DB {
+	return &DB{db: tx, queries: d.queries}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args, err := d.translate(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.db.ExecContext(ctx, query, args...)
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	query, _, err := d.translate(query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.db.PrepareContext(ctx, query)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args, err := d.translate(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.db.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext reports a query without an SQLite version from Scan. Since
+// *sql.Row can't be built with an error outside of database/sql, the error is
+// returned by an argument that database/sql fails to convert.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args, err := d.translate(query, args)
+	if err != nil {
+		return d.db.QueryRowContext(ctx, "SELECT ?", failingArg{err})
+	}
+
+	return d.db.QueryRowContext(ctx, query, args...)
+}
+
+// failingArg is a query argument whose conversion fails with err, before the
+// query is sent to SQLite.
+type failingArg struct {
+	err error
+}
+
+func (a failingArg) Value() (driver.Value, error) {
+	return nil, a.err
+}
+
+func (d *DB) translate(query string, args []interface{}) (string, []interface{}, error) {
+	name := queryName(query)
+	if name == "" {
+		return "", nil, fmt.Errorf("sqlite: query has no name: %q", query)
+	}
+
+	translated, ok := d.queries[name]
+	if !ok {
+		return "", nil, fmt.Errorf("sqlite: no SQLite version of query %s", name)
+	}
+
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		value, err := convertArg(arg)
+		if err != nil {
+			return "", nil, fmt.Errorf("sqlite: %s argument %d: %w", name, i+1, err)
+		}
+		converted[i] = value
+	}
+
+	// The search query is written for websearch_to_tsquery, which SQLite
+	// doesn't have, so it's translated to FTS5's own syntax.
+	if name == "SearchPostsForUser" && len(converted) > 0 {
+		if query, ok := converted[0].(string); ok {
+			converted[0] = MatchQuery(query)
+		}
+	}
+
+	return translated, converted, nil
+}
+
+// convertArg turns a query argument into a value SQLite stores and compares
+// the way PostgreSQL would: UUIDs and times become text, and arrays become
+// JSON arrays for use with json_each.
+func convertArg(arg interface{}) (interface{}, error) {
+	if array, ok := arg.(pq.GenericArray); ok {
+		return convertArray(array.A)
+	}
+
+	if valuer, ok := arg.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+		arg = value
+	}
+
+	if t, ok := arg.(time.Time); ok {
+		return t.UTC().Format(TimeFormat), nil
+	}
+
+	return arg, nil
+}
+
+func convertArray(array interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(array)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("unsupported array type %T", array)
+	}
+
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		value, err := convertArg(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(encoded), nil
+}
+
+// MatchQuery turns a web search style query, the syntax PostgreSQL's
+// websearch_to_tsquery accepts, into an FTS5 MATCH expression. Words and
+// "quoted phrases" become FTS5 strings, so punctuation such as the
+// apostrophe in "pgvector's" isn't parsed as FTS5 syntax. OR separates
+// alternatives and a leading - excludes a word or phrase. FTS5 can't exclude
+// terms on their own, so alternatives made only of exclusions are dropped.
+// MatchQuery returns "" if nothing in query can be searched for.
+func MatchQuery(query string) string {
+	var alternatives, terms, excluded []string
+	endAlternative := func() {
+		if len(terms) > 0 {
+			alternatives = append(alternatives, strings.Join(append(terms, excluded...), " "))
+		}
+		terms, excluded = nil, nil
+	}
+
+	for {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if query == "" {
+			break
+		}
+
+		negated := false
+		if rest, ok := strings.CutPrefix(query, "-"); ok {
+			negated, query = true, rest
+		}
+
+		var text string
+		quoted := false
+		if rest, ok := strings.CutPrefix(query, `"`); ok {
+			text, query, _ = strings.Cut(rest, `"`)
+			quoted = true
+		} else {
+			end := strings.IndexFunc(query, func(r rune) bool {
+				return unicode.IsSpace(r) || r == '"'
+			})
+			if end < 0 {
+				end = len(query)
+			}
+			text, query = query[:end], query[end:]
+		}
+
+		if !quoted && !negated && strings.EqualFold(text, "or") {
+			endAlternative()
+			continue
+		}
+
+		// Terms without letters or digits have no tokens to match, and would
+		// make FTS5 fail with an empty phrase.
+		if !strings.ContainsFunc(text, func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsNumber(r)
+		}) {
+			continue
+		}
+
+		phrase := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+		if negated {
+			excluded = append(excluded, "NOT "+phrase)
+		} else {
+			terms = append(terms, phrase)
+		}
+	}
+	endAlternative()
+
+	return strings.Join(alternatives, " OR ")
+}
+
+func queryName(query string) string {
+	line, _, _ := strings.Cut(query, "\n")
+	if !strings.HasPrefix(line, namePrefix) {
+		return ""
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, namePrefix))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return fields[0]
+}
+
+// IsUniqueViolation reports whether err was caused by a UNIQUE or PRIMARY KEY
+// constraint.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// ConstraintColumns returns the columns SQLite names in a UNIQUE constraint
+// error, such as "feeds.url", since it doesn't report the constraint's name.
+func ConstraintColumns(err error) string {
+	if !IsUniqueViolation(err) {
+		return ""
+	}
+
+	var sqliteErr *sqlite.Error
+	errors.As(err, &sqliteErr)
+
+	_, columns, ok := strings.Cut(sqliteErr.Error(), "UNIQUE constraint failed: ")
+	if !ok {
+		return ""
+	}
+
+	columns, _, _ = strings.Cut(columns, " (")
+	return columns
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
 	"html"
 	"io"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,10 +24,15 @@ import (
 
 type RSSFeed struct {
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Language    string `xml:"language"`
+		Generator   string `xml:"generator"`
+		Image       struct {
+			URL string `xml:"url"`
+		} `xml:"image"`
+		Item []RSSItem `xml:"item"`
 	} `xml:"channel"`
 }
 
@@ -31,83 +40,779 @@ type RSSItem struct {
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
 	PubDate     string `xml:"pubDate"`
 }
 
 type state struct {
-	db  *database.Queries
-	cfg *config.Config
+	db     database.Querier
+	conn   *sql.DB
+	driver string
+	cfg    *config.Config
 }
 
 type command struct {
-	name string
-	args []string
+	name   string
+	args   []string
+	output outputFormat
+}
+
+// commandSpec describes a command for the registry: what it does, the
+// arguments and flags it accepts, and the handler that runs it. Commands
+// with subcommands, such as feed, have no handler of their own. Listing
+// commands also accept --output to print JSON or CSV.
+//
+// Commands with flags set flags instead of handler. It defines the flags on
+// fs, parsing them into the command's options, and returns the handler that
+// runs the command with those options.
+//
+// complete lists how shell completion completes each argument. The last
+// completer is used for any further arguments when the usage ends in "...".
+type commandSpec struct {
+	name        string
+	usage       string
+	description string
+	minArgs     int
+	listing     bool
+	flags       func(fs *flag.FlagSet) func(*state, command) error
+	complete    []completer
+	handler     func(*state, command) error
+	subcommands []commandSpec
+}
+
+// flagSet returns a new flag set with the command's flags defined, and the
+// handler that runs the command with the values they're parsed into.
+func (c *commandSpec) flagSet(name string) (*flag.FlagSet, func(*state, command) error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	handler := c.handler
+	if c.flags != nil {
+		handler = c.flags(fs)
+	}
+
+	if c.listing {
+		format := outputFormat(outputTable)
+		fs.Var(&format, "output", "print `format`: table, json or csv")
+
+		next := handler
+		handler = func(s *state, cmd command) error {
+			cmd.output = format
+			return next(s, cmd)
+		}
+	}
+
+	return fs, handler
+}
+
+// synopsis returns how to call the command, such as
+// "gator feed rm [flags] <url>".
+func (c *commandSpec) synopsis(name string) string {
+	synopsis := "gator " + name
+	if len(c.subcommands) > 0 {
+		return synopsis + " <command> [arguments]"
+	}
+
+	fs, _ := c.flagSet(name)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		synopsis += " [flags]"
+	}
+
+	if c.usage != "" {
+		synopsis += " " + c.usage
+	}
+
+	return synopsis
+}
+
+func (c *commandSpec) subcommand(name string) (*commandSpec, bool) {
+	for i := range c.subcommands {
+		if c.subcommands[i].name == name {
+			return &c.subcommands[i], true
+		}
+	}
+	return nil, false
 }
 
 type commands struct {
-	cmds map[string]func(*state, command) error
+	specs []commandSpec
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
-	c.cmds[name] = f
+func (c *commands) register(spec commandSpec) {
+	c.specs = append(c.specs, spec)
 }
 
-func (c *commands) run(s *state, cmd command) error {
-	handler, ok := c.cmds[cmd.name]
+func (c *commands) lookup(name string) (*commandSpec, bool) {
+	for i := range c.specs {
+		if c.specs[i].name == name {
+			return &c.specs[i], true
+		}
+	}
+	return nil, false
+}
+
+// resolve finds the command called by name and args, following
+// subcommands, and returns it with its full name and remaining arguments.
+func (c *commands) resolve(name string, args []string) (*commandSpec, string, []string, error) {
+	spec, ok := c.lookup(name)
 	if !ok {
-		return fmt.Errorf("command not found")
+		return nil, "", nil, fmt.Errorf("unknown command %q, run 'gator help' for a list of commands", name)
+	}
+
+	for len(spec.subcommands) > 0 && len(args) > 0 && !isHelpFlag(args[0]) {
+		subcommand, ok := spec.subcommand(args[0])
+		if !ok {
+			return nil, "", nil, fmt.Errorf("unknown %s command %q, run 'gator help %s' for a list of commands", name, args[0], name)
+		}
+
+		spec = subcommand
+		name += " " + subcommand.name
+		args = args[1:]
 	}
 
-	return handler(s, cmd)
+	return spec, name, args, nil
 }
 
-func main() {
-	cmds := commands{
-		cmds: make(map[string]func(*state, command) error),
-	}
-	cmds.register("login", handlerLogin)
-	cmds.register("register", handlerRegister)
-	cmds.register("reset", handlerReset)
-	cmds.register("users", handlerUsers)
-	cmds.register("agg", handlerAgg)
-	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	cmds.register("feeds", handlerFeeds)
-	cmds.register("follow", middlewareLoggedIn(handlerFollow))
-	cmds.register("following", middlewareLoggedIn(handlerFollowing))
-	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
-	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
+// parseCommandLine returns the command that gator's arguments call. --output
+// may also be given before the command name, as in gator --output csv
+// following, so it's moved after the command's name, where it's parsed with
+// the command's other flags. It reports false if no command was given.
+func (c *commands) parseCommandLine(args []string) (command, bool) {
+	var leading []string
+	for len(args) > 0 {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		if !strings.HasPrefix(args[0], "-") || name != "output" {
+			break
+		}
 
-	loadedConfig, err := config.Read()
+		n := 2
+		if hasValue || len(args) == 1 {
+			n = 1
+		}
+		leading, args = append(leading, args[:n]...), args[n:]
+	}
+
+	if len(args) == 0 {
+		return command{}, false
+	}
+
+	cmd := command{name: args[0], args: args[1:]}
+	if len(leading) == 0 {
+		return cmd, true
+	}
+
+	// Subcommand names have to stay in front of the flags.
+	_, _, rest, err := c.resolve(cmd.name, cmd.args)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		rest = cmd.args
+	}
+	names := cmd.args[:len(cmd.args)-len(rest)]
+	cmd.args = slices.Concat(names, leading, rest)
+
+	return cmd, true
+}
+
+// wantsHelp reports whether cmd only asks for help, with gator help or a help
+// flag, which doesn't need the config or the database.
+func (c *commands) wantsHelp(cmd command) bool {
+	if cmd.name == "help" {
+		return true
+	}
+
+	spec, name, args, err := c.resolve(cmd.name, cmd.args)
+	if err != nil {
+		return false
+	}
+
+	if len(spec.subcommands) > 0 {
+		return len(args) > 0 && isHelpFlag(args[0])
+	}
+
+	fs, _ := spec.flagSet(name)
+	_, err = parseArgs(fs, args)
+	return errors.Is(err, flag.ErrHelp)
+}
+
+func (c *commands) run(s *state, cmd command) error {
+	spec, name, args, err := c.resolve(cmd.name, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	if len(spec.subcommands) > 0 {
+		if len(args) > 0 && isHelpFlag(args[0]) {
+			c.printHelp(name, spec)
+			return nil
+		}
+		return fmt.Errorf("usage: %s", spec.synopsis(name))
+	}
+
+	fs, handler := spec.flagSet(name)
+	args, err = parseArgs(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		c.printHelp(name, spec)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%v\nusage: %s", err, spec.synopsis(name))
+	}
+
+	if len(args) < spec.minArgs {
+		return fmt.Errorf("usage: %s", spec.synopsis(name))
+	}
+
+	err = handler(s, command{name: name, args: args})
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		return fmt.Errorf("%v\nusage: %s", usageErr.err, spec.synopsis(name))
+	}
+
+	return friendlyError(err)
+}
+
+// usageError is returned by handlers for arguments they can't use, and is
+// printed along with the command's usage.
+type usageError struct {
+	err error
+}
+
+func usageErrorf(format string, a ...any) error {
+	return usageError{fmt.Errorf(format, a...)}
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// printUsage lists every command with its description.
+func (c *commands) printUsage() {
+	fmt.Println("Usage: gator <command> [arguments]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	printCommandList(c.specs)
+	fmt.Println()
+	fmt.Println("Run 'gator help <command>' for more information about a command.")
+}
+
+// printHelp describes a command: how to call it, what it does and its flags
+// or subcommands.
+func (c *commands) printHelp(name string, spec *commandSpec) {
+	fmt.Printf("Usage: %s\n\n", spec.synopsis(name))
+	fmt.Println(spec.description)
+
+	if len(spec.subcommands) > 0 {
+		fmt.Println()
+		fmt.Println("Commands:")
+		printCommandList(spec.subcommands)
 		return
 	}
 
-	db, err := sql.Open("postgres", loadedConfig.DB_URL)
+	fs, _ := spec.flagSet(name)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.SetOutput(os.Stdout)
+		fs.PrintDefaults()
+	}
+}
+
+func printCommandList(specs []commandSpec) {
+	width := 0
+	for _, spec := range specs {
+		width = max(width, len(spec.name))
+	}
+
+	for _, spec := range specs {
+		fmt.Printf("  %-*s  %s\n", width, spec.name, spec.description)
+	}
+}
+
+func (c *commands) handlerHelp(s *state, cmd command) error {
+	if len(cmd.args) == 0 {
+		c.printUsage()
+		return nil
+	}
+
+	spec, name, _, err := c.resolve(cmd.args[0], cmd.args[1:])
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+
+	c.printHelp(name, spec)
+
+	return nil
+}
+
+// parseArgs parses the flags in args with fs, allowing flags and positional
+// arguments to be interleaved, and returns the positional arguments.
+// Everything after a "--" is positional, even if it starts with a dash.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+
+		args = rest
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// optionalDuration returns a flag.FlagSet.Func setter that parses a duration
+// into *d, which stays nil unless the flag is given.
+func optionalDuration(d **time.Duration) func(string) error {
+	return func(value string) error {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("parse error")
+		}
+		*d = &duration
+		return nil
+	}
+}
+
+// optionalInt returns a flag.FlagSet.Func setter that parses an int into *n,
+// which stays nil unless the flag is given.
+func optionalInt(n **int) func(string) error {
+	return func(value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("parse error")
+		}
+		*n = &i
+		return nil
+	}
+}
+
+// newCommands returns the registry of every gator command.
+func newCommands() *commands {
+	cmds := &commands{}
+
+	cmds.register(commandSpec{
+		name:        "login",
+		usage:       "<username>",
+		description: "Log in as an existing user",
+		minArgs:     1,
+		complete:    []completer{completeUsers},
+		handler:     handlerLogin,
+	})
+	cmds.register(commandSpec{
+		name:        "register",
+		usage:       "<username>",
+		description: "Create a user and log in as them",
+		minArgs:     1,
+		listing:     true,
+		handler:     handlerRegister,
+	})
+	cmds.register(commandSpec{
+		name:        "reset",
+		description: "Delete every user, feed and post",
+		handler:     handlerReset,
+	})
+	cmds.register(commandSpec{
+		name:        "users",
+		description: "List all users",
+		listing:     true,
+		handler:     handlerUsers,
+	})
+	cmds.register(commandSpec{
+		name:        "user",
+		description: "Manage users",
+		subcommands: []commandSpec{
+			{
+				name:        "delete",
+				usage:       "<username>",
+				description: "Delete a user and their follows, handing the feeds they added over to another follower",
+				minArgs:     1,
+				complete:    []completer{completeUsers},
+				handler:     handlerUserDelete,
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "agg",
+		usage:       "<interval>",
+		description: "Fetch the followed feeds every interval, such as 1m or 1h",
+		minArgs:     1,
+		handler:     handlerAgg,
+	})
+	cmds.register(commandSpec{
+		name:        "gc",
+		description: "Delete feeds nobody has followed for a while, along with their posts",
+		flags: func(fs *flag.FlagSet) func(*state, command) error {
+			var opts gcOptions
+			fs.Func("grace", "the `duration` a feed must have been unfollowed for before it is deleted (default feed_gc_grace_period from the config, or 720h)", optionalDuration(&opts.grace))
+			fs.BoolVar(&opts.dryRun, "dry-run", false, "only list the feeds that would be deleted")
+			return func(s *state, cmd command) error {
+				return handlerGC(s, cmd, opts)
+			}
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "prune",
+		description: "Delete posts outside the retention policies, except starred ones",
+		flags: func(fs *flag.FlagSet) func(*state, command) error {
+			var opts pruneOptions
+			fs.BoolVar(&opts.dryRun, "dry-run", false, "only report the posts that would be deleted")
+			return func(s *state, cmd command) error {
+				return handlerPrune(s, cmd, opts)
+			}
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "addfeed",
+		usage:       "[name] <url>",
+		description: "Add a feed and follow it, naming it after its channel title unless a name is given",
+		listing:     true,
+		minArgs:     1,
+		handler:     middlewareLoggedIn(handlerAddFeed),
+	})
+	cmds.register(commandSpec{
+		name:        "feeds",
+		description: "List all feeds",
+		listing:     true,
+		handler:     handlerFeeds,
+	})
+	cmds.register(commandSpec{
+		name:        "feed",
+		description: "Manage the feeds you added or follow",
+		subcommands: []commandSpec{
+			{
+				name:        "rm",
+				usage:       "<url>",
+				description: "Remove a feed, handing it over to another follower if there is one",
+				minArgs:     1,
+				flags: func(fs *flag.FlagSet) func(*state, command) error {
+					var opts feedRemoveOptions
+					fs.BoolVar(&opts.force, "force", false, "delete the feed even if other users follow it")
+					return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+						return handlerFeedRemove(s, cmd, user, opts)
+					})
+				},
+				complete: []completer{completeOwnedFeeds},
+			},
+			{
+				name:        "rename",
+				usage:       "<url> <name>",
+				description: "Rename a feed",
+				minArgs:     2,
+				complete:    []completer{completeOwnedFeeds},
+				handler:     middlewareLoggedIn(handlerFeedRename),
+			},
+			{
+				name:        "set-url",
+				usage:       "<url> <new-url>",
+				description: "Change the URL a feed is fetched from",
+				minArgs:     2,
+				complete:    []completer{completeOwnedFeeds},
+				handler:     middlewareLoggedIn(handlerFeedSetUrl),
+			},
+			{
+				name:        "retention",
+				usage:       "<url>",
+				description: "Show or change how long you keep the posts of a feed you follow",
+				minArgs:     1,
+				flags: func(fs *flag.FlagSet) func(*state, command) error {
+					var opts feedRetentionOptions
+					fs.Func("max-age", "delete posts older than this `duration`, 0 to keep them forever", optionalDuration(&opts.maxAge))
+					fs.Func("max-posts", "keep at most `n` posts, 0 for no limit", optionalInt(&opts.maxPosts))
+					fs.BoolVar(&opts.global, "global", false, "go back to the global settings, except for the limits also given")
+					return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+						return handlerFeedRetention(s, cmd, user, opts)
+					})
+				},
+				complete: []completer{completeFollowedFeeds},
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "follow",
+		usage:       "<url>",
+		description: "Follow a feed that was already added",
+		minArgs:     1,
+		complete:    []completer{completeFeeds},
+		handler:     middlewareLoggedIn(handlerFollow),
+	})
+	cmds.register(commandSpec{
+		name:        "following",
+		description: "List the feeds you follow",
+		listing:     true,
+		flags: func(fs *flag.FlagSet) func(*state, command) error {
+			var opts followingOptions
+			fs.StringVar(&opts.tag, "tag", "", "only list feeds with this tag")
+			return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+				return handlerFollowing(s, cmd, user, opts)
+			})
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "unfollow",
+		usage:       "<url>",
+		description: "Unfollow a feed",
+		minArgs:     1,
+		complete:    []completer{completeFollowedFeeds},
+		handler:     middlewareLoggedIn(handlerUnfollow),
+	})
+	cmds.register(commandSpec{
+		name:        "title",
+		usage:       "<url> [title]",
+		description: "Set your own title for a followed feed, or clear it",
+		minArgs:     1,
+		complete:    []completer{completeFollowedFeeds},
+		handler:     middlewareLoggedIn(handlerTitle),
+	})
+	cmds.register(commandSpec{
+		name:        "tag",
+		usage:       "<url> <tag>...",
+		description: "Tag a followed feed",
+		minArgs:     2,
+		complete:    []completer{completeFollowedFeeds, completeTags},
+		handler:     middlewareLoggedIn(handlerTag),
+	})
+	cmds.register(commandSpec{
+		name:        "untag",
+		usage:       "<url> <tag>...",
+		description: "Remove tags from a followed feed",
+		minArgs:     2,
+		complete:    []completer{completeFollowedFeeds, completeTags},
+		handler:     middlewareLoggedIn(handlerUntag),
+	})
+	cmds.register(commandSpec{
+		name:        "tags",
+		description: "List your tags",
+		listing:     true,
+		handler:     middlewareLoggedIn(handlerTags),
+	})
+	cmds.register(commandSpec{
+		name:        "browse",
+		usage:       "[limit]",
+		description: "Show the latest posts from the feeds you follow",
+		listing:     true,
+		flags: func(fs *flag.FlagSet) func(*state, command) error {
+			var opts browseOptions
+			fs.BoolVar(&opts.unread, "unread", false, "only show posts you haven't read")
+			fs.BoolVar(&opts.read, "read", false, "only show posts you have already read")
+			fs.BoolVar(&opts.markRead, "mark-read", false, "mark the shown posts as read")
+			fs.StringVar(&opts.feedURL, "feed", "", "only show posts from the feed with this URL")
+			fs.StringVar(&opts.tag, "tag", "", "only show posts from feeds with this tag")
+			fs.StringVar(&opts.since, "since", "", "only show posts from this date on (YYYY-MM-DD or RFC 3339)")
+			fs.StringVar(&opts.until, "until", "", "only show posts before this date (YYYY-MM-DD or RFC 3339)")
+			fs.StringVar(&opts.sortBy, "sort", "fetched", "sort posts by `fetched` or published time")
+			fs.StringVar(&opts.cursor, "cursor", "", "continue from the cursor printed by a previous browse")
+			return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+				return handlerBrowse(s, cmd, user, opts)
+			})
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "posts",
+		usage:       "<feed-url>",
+		description: "Show the latest posts of a feed",
+		listing:     true,
+		minArgs:     1,
+		flags: func(fs *flag.FlagSet) func(*state, command) error {
+			var opts postsOptions
+			fs.IntVar(&opts.limit, "limit", 10, "maximum number of posts to show")
+			return func(s *state, cmd command) error {
+				return handlerPosts(s, cmd, opts)
+			}
+		},
+		complete: []completer{completeFeeds},
+	})
+	cmds.register(commandSpec{
+		name:        "search",
+		usage:       "<query>",
+		description: "Search the posts of the feeds you follow",
+		listing:     true,
+		minArgs:     1,
+		flags: func(fs *flag.FlagSet) func(*state, command) error {
+			var opts searchOptions
+			fs.IntVar(&opts.limit, "limit", 10, "maximum number of posts to show")
+			return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+				return handlerSearch(s, cmd, user, opts)
+			})
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "read",
+		usage:       "<post>",
+		description: "Mark a post as read, by the ID printed by browse or by its URL",
+		minArgs:     1,
+		handler:     middlewareLoggedIn(handlerRead),
+	})
+	cmds.register(commandSpec{
+		name:        "mark-all-read",
+		usage:       "[feed-url]",
+		description: "Mark every post as read, optionally only for one feed",
+		complete:    []completer{completeFollowedFeeds},
+		handler:     middlewareLoggedIn(handlerMarkAllRead),
+	})
+	cmds.register(commandSpec{
+		name:        "star",
+		usage:       "<post>",
+		description: "Save a post for later, starred posts are never pruned",
+		minArgs:     1,
+		handler:     middlewareLoggedIn(handlerStar),
+	})
+	cmds.register(commandSpec{
+		name:        "unstar",
+		usage:       "<post>",
+		description: "Unstar a post",
+		minArgs:     1,
+		handler:     middlewareLoggedIn(handlerUnstar),
+	})
+	cmds.register(commandSpec{
+		name:        "starred",
+		description: "List your starred posts",
+		listing:     true,
+		handler:     middlewareLoggedIn(handlerStarred),
+	})
+	cmds.register(commandSpec{
+		name:        "tui",
+		description: "Read the posts of the feeds you follow in a full-screen reader",
+		flags: func(fs *flag.FlagSet) func(*state, command) error {
+			var opts tuiOptions
+			fs.DurationVar(&opts.refresh, "refresh", 30*time.Second, "how often to check for new posts")
+			return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+				return handlerTUI(s, cmd, user, opts)
+			})
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "import",
+		description: "Import feeds to follow",
+		subcommands: []commandSpec{
+			{
+				name:        "opml",
+				usage:       "<file>",
+				description: "Follow the feeds in an OPML file, tagging them with their folders",
+				minArgs:     1,
+				handler:     middlewareLoggedIn(handlerImport),
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "export",
+		description: "Export the feeds you follow",
+		subcommands: []commandSpec{
+			{
+				name:        "opml",
+				usage:       "[file]",
+				description: "Write the feeds you follow as an OPML document, to stdout or to file",
+				handler:     middlewareLoggedIn(handlerExport),
+			},
+		},
+	})
+	cmds.register(commandSpec{
+		name:        "migrate",
+		usage:       "up|down|status",
+		description: "Apply, roll back or list the database schema migrations",
+		minArgs:     1,
+		complete:    []completer{completeWords("up", "down", "status")},
+		handler:     handlerMigrate,
+	})
+	cmds.register(commandSpec{
+		name:        "completion",
+		usage:       "bash|zsh|fish",
+		description: "Print a script that completes gator commands, feed URLs, tags and usernames in your shell",
+		minArgs:     1,
+		complete:    []completer{completeWords("bash", "zsh", "fish")},
+		handler:     handlerCompletion,
+	})
+	cmds.register(commandSpec{
+		name:        "help",
+		usage:       "[command]",
+		description: "Show the list of commands or the help of a command",
+		complete:    []completer{cmds.completeHelp, cmds.completeHelp},
+		handler:     cmds.handlerHelp,
+	})
+
+	return cmds
+}
+
+// schemaFreeCommands don't use the database, or manage its schema, so they
+// run before migrations are applied.
+var schemaFreeCommands = []string{"migrate", "help"}
+
+func main() {
+	cmds := newCommands()
+
+	cmd, ok := cmds.parseCommandLine(os.Args[1:])
+	if !ok {
+		cmds.printUsage()
 		os.Exit(1)
 		return
 	}
 
-	dbQueries := database.New(db)
-	defer db.Close()
+	if isHelpFlag(cmd.name) {
+		cmd = command{name: "help"}
+	}
 
-	s := &state{
-		cfg: &loadedConfig,
-		db:  dbQueries,
+	// Help and the completion scripts are shown before the config is read and
+	// the database opened, so they work before gator is set up.
+	if cmds.wantsHelp(cmd) || cmd.name == "completion" {
+		if err := cmds.run(&state{}, cmd); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	args := os.Args
+	// The completion scripts run gator __complete with the words of the
+	// command line being completed, unfinished flags included, so they're
+	// not parsed as a command. Whatever goes to stdout becomes a candidate,
+	// so nothing is printed when the config or database can't be opened.
+	if cmd.name == completeCommand {
+		var s *state
+		cmds.printCompletions(func() (*state, error) {
+			if s != nil {
+				return s, nil
+			}
+
+			var err error
+			s, err = openState()
+			return s, err
+		}, cmd.args)
+		if s != nil {
+			s.conn.Close()
+		}
+		return
+	}
 
-	if len(args) < 2 {
-		fmt.Println("Usage: gator <command> <args>")
+	s, err := openState()
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 		return
 	}
+	defer s.conn.Close()
+
+	if !slices.Contains(schemaFreeCommands, cmd.name) {
+		if err := checkSchemaVersion(s.conn, s.driver); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+			return
+		}
+	}
 
-	cmd := command{name: args[1], args: args[2:]}
 	if err := cmds.run(s, cmd); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -115,6 +820,26 @@ func main() {
 	}
 }
 
+// openState reads the config and opens the database it points at.
+func openState() (*state, error) {
+	loadedConfig, err := config.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	db, store, driver, err := openDatabase(loadedConfig.DB_URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state{
+		cfg:    &loadedConfig,
+		db:     store,
+		conn:   db,
+		driver: driver,
+	}, nil
+}
+
 func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
@@ -146,6 +871,7 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	for i := 0; i < len(feed.Channel.Item); i++ {
 		feed.Channel.Item[i].Title = html.UnescapeString(feed.Channel.Item[i].Title)
 		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
+		feed.Channel.Item[i].Content = html.UnescapeString(feed.Channel.Item[i].Content)
 	}
 
 	return &feed, nil
@@ -186,10 +912,27 @@ func scrapeFeeds(s *state) error {
 		return err
 	}
 
+	_, err = updateFeedMetadata(s, feed.ID, data)
+	if err != nil {
+		fmt.Printf("Error updating metadata for: %s\n", feed.Name)
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	// Items older than the retention window would only be pruned again, so
+	// they aren't stored in the first place.
+	maxAge, err := feedRetentionMaxAge(s, feed)
+	if err != nil {
+		return err
+	}
+
 	newPosts := 0
 
 	for _, item := range data.Channel.Item {
 		publishedAt, err := time.Parse(time.RFC1123Z, item.PubDate)
+		if err == nil && maxAge > 0 && time.Since(publishedAt) > maxAge {
+			continue
+		}
+
 		post, err := s.db.CreatePost(
 			context.Background(),
 			database.CreatePostParams{
@@ -206,11 +949,12 @@ func scrapeFeeds(s *state) error {
 					Time:  publishedAt,
 					Valid: err == nil,
 				},
-				FeedID: feed.ID,
+				FeedID:  feed.ID,
+				Content: nullString(item.Content),
 			},
 		)
 		if err != nil {
-			if !strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			if !isUniqueViolation(err) {
 				fmt.Printf("Error creating post for: %s\n", item.Title)
 				fmt.Printf("Error: %v\n", err)
 			}
@@ -230,3 +974,26 @@ func scrapeFeeds(s *state) error {
 
 	return nil
 }
+
+func updateFeedMetadata(s *state, feedID uuid.UUID, data *RSSFeed) (database.Feed, error) {
+	return s.db.UpdateFeedMetadata(
+		context.Background(),
+		database.UpdateFeedMetadataParams{
+			ID:          feedID,
+			SiteLink:    nullString(data.Channel.Link),
+			Description: nullString(data.Channel.Description),
+			Language:    nullString(data.Channel.Language),
+			ImageUrl:    nullString(data.Channel.Image.URL),
+			Generator:   nullString(data.Channel.Generator),
+			UpdatedAt:   time.Now(),
+		},
+	)
+}
+
+func nullString(value string) sql.NullString {
+	value = strings.TrimSpace(value)
+	return sql.NullString{
+		String: value,
+		Valid:  value != "",
+	}
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thihxm/gator/internal/config"
+	"github.com/thihxm/gator/internal/database"
+	"github.com/thihxm/gator/internal/memory"
+	"github.com/thihxm/gator/internal/sqlite"
+)
+
+// newTestState returns a state backed by an in-memory store, with the config
+// file written to a temporary home directory.
+func newTestState(t *testing.T) *state {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	return &state{
+		db:  memory.New(),
+		cfg: &config.Config{},
+	}
+}
+
+// run runs the command name with args the way gator does, and returns what
+// it printed.
+func run(t *testing.T, s *state, name string, args ...string) (string, error) {
+	t.Helper()
+
+	return capture(t, func() error {
+		return newCommands().run(s, command{name: name, args: args})
+	})
+}
+
+// mustRun is like run but fails the test if the command returns an error.
+func mustRun(t *testing.T, s *state, name string, args ...string) string {
+	t.Helper()
+
+	out, err := run(t, s, name, args...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\noutput:\n%s", err, out)
+	}
+
+	return out
+}
+
+// scrape fetches the next feed once and returns what it printed.
+func scrape(t *testing.T, s *state) (string, error) {
+	t.Helper()
+
+	return capture(t, func() error { return scrapeFeeds(s) })
+}
+
+// capture runs fn and returns what it printed to stdout.
+func capture(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := make(chan string)
+	go func() {
+		var b strings.Builder
+		io.Copy(&b, r)
+		output <- b.String()
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	err = fn()
+	os.Stdout = stdout
+
+	w.Close()
+	out := <-output
+	r.Close()
+
+	return out, err
+}
+
+func assertContains(t *testing.T, out string, want ...string) {
+	t.Helper()
+
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("output doesn't contain %q:\n%s", w, out)
+		}
+	}
+}
+
+func assertNotContains(t *testing.T, out string, unwanted ...string) {
+	t.Helper()
+
+	for _, u := range unwanted {
+		if strings.Contains(out, u) {
+			t.Errorf("output contains %q:\n%s", u, out)
+		}
+	}
+}
+
+func assertError(t *testing.T, err error, want string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("expected an error containing %q, got nil", want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected an error containing %q, got %q", want, err)
+	}
+}
+
+// assertRow checks that a line of a table shows cells in order.
+func assertRow(t *testing.T, out string, cells ...string) {
+	t.Helper()
+
+	quoted := make([]string, len(cells))
+	for i, cell := range cells {
+		quoted[i] = regexp.QuoteMeta(cell)
+	}
+
+	pattern := regexp.MustCompile(`(?m)^` + strings.Join(quoted, `\s{2,}`) + `(\s|$)`)
+	if !pattern.MatchString(out) {
+		t.Errorf("output doesn't have a row with %q:\n%s", cells, out)
+	}
+}
+
+var postTitlePattern = regexp.MustCompile(`(?m)^[0-9a-f]{8}  (.+)$`)
+
+// postTitles returns the titles of the posts in a post list, in order.
+func postTitles(out string) []string {
+	var titles []string
+	for _, match := range postTitlePattern.FindAllStringSubmatch(out, -1) {
+		titles = append(titles, match[1])
+	}
+	return titles
+}
+
+func assertPostTitles(t *testing.T, out string, want ...string) {
+	t.Helper()
+
+	if got := postTitles(out); !slices.Equal(got, want) {
+		t.Errorf("expected posts %q, got %q:\n%s", want, got, out)
+	}
+}
+
+type testItem struct {
+	title       string
+	link        string
+	description string
+	content     string
+	published   time.Time
+}
+
+// feedServer serves RSS feeds whose items can be changed during a test.
+type feedServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	feeds map[string]string
+}
+
+func newFeedServer(t *testing.T) *feedServer {
+	t.Helper()
+
+	server := &feedServer{feeds: make(map[string]string)}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		body, ok := server.feeds[r.URL.Path]
+		server.mu.Unlock()
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/rss+xml")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// setFeed serves an RSS feed with the given title and items at path and
+// returns its URL.
+func (f *feedServer) setFeed(path, title string, items ...testItem) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?>`)
+	b.WriteString(`<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/"><channel>`)
+	fmt.Fprintf(&b, "<title>%s</title>", html.EscapeString(title))
+	fmt.Fprintf(&b, "<link>https://example.com%s</link>", path)
+	b.WriteString("<description>A feed for tests</description><language>en</language>")
+	for _, item := range items {
+		b.WriteString("<item>")
+		fmt.Fprintf(&b, "<title>%s</title>", html.EscapeString(item.title))
+		fmt.Fprintf(&b, "<link>%s</link>", html.EscapeString(item.link))
+		if item.description != "" {
+			fmt.Fprintf(&b, "<description>%s</description>", html.EscapeString(item.description))
+		}
+		if item.content != "" {
+			fmt.Fprintf(&b, "<content:encoded>%s</content:encoded>", html.EscapeString(item.content))
+		}
+		if !item.published.IsZero() {
+			fmt.Fprintf(&b, "<pubDate>%s</pubDate>", item.published.Format(time.RFC1123Z))
+		}
+		b.WriteString("</item>")
+	}
+	b.WriteString("</channel></rss>")
+
+	f.mu.Lock()
+	f.feeds[path] = b.String()
+	f.mu.Unlock()
+
+	return f.URL + path
+}
+
+// fetchAll scrapes every followed feed once.
+func fetchAll(t *testing.T, s *state) {
+	t.Helper()
+
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range feeds {
+		if _, err := scrape(t, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScrapeFeeds(t *testing.T) {
+	s := newTestState(t)
+	server := newFeedServer(t)
+
+	url := server.setFeed("/go", "Go Blog",
+		testItem{title: "First", link: "https://example.com/go/1", description: "One", published: time.Now().Add(-2 * time.Hour)},
+		testItem{title: "Second", link: "https://example.com/go/2", content: "Two", published: time.Now().Add(-time.Hour)},
+	)
+
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", url)
+
+	out, err := scrape(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, out, "Fetching feed `Go Blog`", "Title: First", "Title: Second", "Found 2 new post(s)")
+
+	out, err = scrape(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, out, "No new posts found")
+	assertNotContains(t, out, "Error")
+
+	feed, err := s.db.GetFeedByUrl(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !feed.LastFetchedAt.Valid {
+		t.Error("the feed wasn't marked as fetched")
+	}
+	if feed.SiteLink.String != "https://example.com/go" || feed.Language.String != "en" {
+		t.Errorf("the feed metadata wasn't updated: %+v", feed)
+	}
+}
+
+func TestScrapeFeedsSkipsItemsOutsideRetention(t *testing.T) {
+	s := newTestState(t)
+	s.cfg.RetentionMaxAge = "24h"
+	server := newFeedServer(t)
+
+	url := server.setFeed("/go", "Go Blog",
+		testItem{title: "Old", link: "https://example.com/go/1", published: time.Now().Add(-48 * time.Hour)},
+		testItem{title: "New", link: "https://example.com/go/2", published: time.Now().Add(-time.Hour)},
+	)
+
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", url)
+
+	out, err := scrape(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, out, "Title: New", "Found 1 new post(s)")
+	assertNotContains(t, out, "Title: Old")
+}
+
+func TestScrapeFeedsSkipsUnfollowedFeeds(t *testing.T) {
+	s := newTestState(t)
+	server := newFeedServer(t)
+	url := server.setFeed("/go", "Go Blog")
+
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", url)
+	mustRun(t, s, "unfollow", url)
+
+	if _, err := scrape(t, s); err == nil {
+		t.Fatal("expected no feed to fetch")
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := fs.Bool("v", false, "")
+	limit := fs.Int("limit", 0, "")
+
+	args, err := parseArgs(fs, []string{"a", "-v", "b", "--limit", "3", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(args, ",") != "a,b,c" || !*verbose || *limit != 3 {
+		t.Errorf("got args %v, verbose %v, limit %d", args, *verbose, *limit)
+	}
+
+	// Arguments after -- aren't parsed as flags.
+	args, err = parseArgs(fs, []string{"a", "--", "-b", "--limit", "-v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(args, ",") != "a,-b,--limit,-v" {
+		t.Errorf("got args %v after --", args)
+	}
+}
+
+func TestParseCommandLine(t *testing.T) {
+	cmds := newCommands()
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"following", "--output", "csv"}, []string{"following", "--output", "csv"}},
+		{[]string{"--output", "csv", "following"}, []string{"following", "--output", "csv"}},
+		{[]string{"--output=json", "browse", "5"}, []string{"browse", "--output=json", "5"}},
+		{[]string{"-output", "json", "user", "delete", "bob"}, []string{"user", "delete", "-output", "json", "bob"}},
+		{[]string{"--output", "csv", "explode", "x"}, []string{"explode", "--output", "csv", "x"}},
+		{[]string{"--help"}, []string{"--help"}},
+		{[]string{"--output", "csv"}, nil},
+		{nil, nil},
+	}
+
+	for _, tt := range tests {
+		cmd, ok := cmds.parseCommandLine(tt.args)
+		if ok != (tt.want != nil) {
+			t.Errorf("parseCommandLine(%q) reported %v", tt.args, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if got := append([]string{cmd.name}, cmd.args...); !slices.Equal(got, tt.want) {
+			t.Errorf("parseCommandLine(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	s, _, goURL, _ := newTestFeeds(t)
+	cmd, _ := cmds.parseCommandLine([]string{"--output", "csv", "following"})
+	out := mustRun(t, s, cmd.name, cmd.args...)
+	assertContains(t, out, "name,url,site_link,tags\n", "Go Blog,"+goURL+",")
+}
+
+// newSQLiteTestState returns a state backed by a migrated SQLite database in a
+// temporary directory, for tests of the SQLite versions of the queries.
+func newSQLiteTestState(t *testing.T) *state {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	conn, store, driver, err := openDatabase("sqlite://" + filepath.Join(t.TempDir(), "gator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s := &state{db: store, conn: conn, driver: driver, cfg: &config.Config{}}
+	mustRun(t, s, "migrate", "up")
+
+	return s
+}
+
+func TestSQLiteTransaction(t *testing.T) {
+	s := newSQLiteTestState(t)
+
+	createUser := func(tx *state, name string) error {
+		_, err := tx.db.CreateUser(context.Background(), database.CreateUserParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Name:      name,
+		})
+		return err
+	}
+
+	err := withTx(s, func(tx *state) error {
+		if err := createUser(tx, "alice"); err != nil {
+			return err
+		}
+		return createUser(tx, "alice")
+	})
+	if !isUniqueViolation(err) {
+		t.Fatalf("expected a unique violation, got %v", err)
+	}
+	assertError(t, friendlyError(err), "a user with that name already exists")
+
+	err = withTx(s, func(tx *state) error {
+		return createUser(tx, "bob")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Name != "bob" {
+		t.Errorf("expected only bob to be created, got %+v", users)
+	}
+}
+
+func TestSQLiteQueries(t *testing.T) {
+	s := newSQLiteTestState(t)
+
+	queries, err := fs.Sub(sqliteQueriesFS, "sql/sqlite/queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqlite.New(s.conn, queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sqlc names every method after its query, so preparing a query by the
+	// method's name checks that it has an SQLite version that SQLite accepts.
+	querier := reflect.TypeFor[database.Querier]()
+	for i := range querier.NumMethod() {
+		name := querier.Method(i).Name
+		stmt, err := db.PrepareContext(context.Background(), "-- name: "+name+" :exec\n")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		stmt.Close()
+	}
+
+	var one int
+	err = db.QueryRowContext(context.Background(), "-- name: Missing :one\nSELECT 1").Scan(&one)
+	assertError(t, err, "no SQLite version of query Missing")
+}
+
+func TestHelp(t *testing.T) {
+	s := newTestState(t)
+
+	out := mustRun(t, s, "help")
+	assertContains(t, out, "Usage: gator <command> [arguments]", "  browse ", "  mark-all-read  Mark every post as read")
+
+	out = mustRun(t, s, "help", "browse")
+	assertContains(t, out, "Usage: gator browse [flags] [limit]", "Show the latest posts", "-unread", "-sort fetched")
+
+	// --help works without being logged in.
+	out = mustRun(t, s, "feed", "rm", "--help")
+	assertContains(t, out, "Usage: gator feed rm [flags] <url>", "-force")
+
+	out = mustRun(t, s, "feed", "-h")
+	assertContains(t, out, "Usage: gator feed <command> [arguments]", "  rename ", "  set-url ")
+
+	out = mustRun(t, s, "help", "user", "delete")
+	assertContains(t, out, "Usage: gator user delete <username>")
+	assertNotContains(t, out, "Flags:")
+
+	_, err := run(t, s, "help", "explode")
+	assertError(t, err, `unknown command "explode"`)
+
+	_, err = run(t, s, "explode")
+	assertError(t, err, "run 'gator help' for a list of commands")
+
+	// Help is shown without the config or a database, so it works before
+	// gator is set up.
+	cmds := newCommands()
+	for _, args := range [][]string{
+		{"help"},
+		{"help", "browse"},
+		{"browse", "--unread", "--help"},
+		{"feed", "-h"},
+		{"feed", "rm", "-help"},
+	} {
+		cmd := command{name: args[0], args: args[1:]}
+		if !cmds.wantsHelp(cmd) {
+			t.Errorf("expected %q to ask for help", args)
+			continue
+		}
+
+		if _, err := run(t, &state{}, cmd.name, cmd.args...); err != nil {
+			t.Errorf("%q: %v", args, err)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"browse", "10"},
+		{"search", "--", "--help"},
+		{"feed", "rm", "https://example.com/feed.xml"},
+		{"explode", "--help"},
+	} {
+		if cmds.wantsHelp(command{name: args[0], args: args[1:]}) {
+			t.Errorf("expected %q not to ask for help", args)
+		}
+	}
+}
+
+func TestFlagErrors(t *testing.T) {
+	s, _, _, _ := newTestFeeds(t)
+
+	_, err := run(t, s, "browse", "--explode")
+	assertError(t, err, "flag provided but not defined: -explode\nusage: gator browse [flags] [limit]")
+
+	_, err = run(t, s, "posts", "--limit", "many", "https://example.com")
+	assertError(t, err, `invalid value "many" for flag -limit`)
+
+	_, err = run(t, s, "feed", "retention", "--max-posts", "many", "https://example.com")
+	assertError(t, err, `invalid value "many" for flag -max-posts: parse error`)
+}
+
+func TestCommandRegistry(t *testing.T) {
+	var check func(name string, spec commandSpec)
+	check = func(name string, spec commandSpec) {
+		if spec.description == "" {
+			t.Errorf("%s has no description", name)
+		}
+		runnable := 0
+		for _, set := range []bool{spec.handler != nil, spec.flags != nil, len(spec.subcommands) > 0} {
+			if set {
+				runnable++
+			}
+		}
+		if runnable != 1 {
+			t.Errorf("%s must have exactly one of a handler, flags or subcommands", name)
+		}
+		for _, subcommand := range spec.subcommands {
+			check(name+" "+subcommand.name, subcommand)
+		}
+	}
+
+	for _, spec := range newCommands().specs {
+		check(spec.name, spec)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 
 	_ "github.com/lib/pq"
 	"github.com/thihxm/gator/internal/database"
@@ -9,6 +10,10 @@ import (
 
 func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
 	return func(s *state, cmd command) error {
+		if s.cfg.CurrentUserName == nil {
+			return fmt.Errorf("you need to login first")
+		}
+
 		currentUser, err := s.db.GetUser(
 			context.Background(),
 			*s.cfg.CurrentUserName,
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql
+var schemaFS embed.FS
+
+func newMigrationProvider(db *sql.DB, driver string) (*goose.Provider, error) {
+	dialect, dir := goose.DialectPostgres, "sql/schema"
+	if driver == driverSQLite {
+		dialect, dir = goose.DialectSQLite3, "sql/sqlite/schema"
+	}
+
+	migrations, err := fs.Sub(schemaFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return goose.NewProvider(dialect, db, migrations)
+}
+
+// checkSchemaVersion makes sure the database schema matches the migrations
+// embedded in this binary, so commands don't fail with confusing query errors.
+func checkSchemaVersion(db *sql.DB, driver string) error {
+	provider, err := newMigrationProvider(db, driver)
+	if err != nil {
+		return err
+	}
+
+	current, target, err := provider.GetVersions(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if current < target {
+		return fmt.Errorf(
+			"the database schema is at version %d but gator needs version %d, run `gator migrate up` first",
+			current,
+			target,
+		)
+	}
+
+	if current > target {
+		return fmt.Errorf(
+			"the database schema is at version %d, which is newer than this gator supports (%d), upgrade gator first",
+			current,
+			target,
+		)
+	}
+
+	return nil
+}
+
+func handlerMigrate(s *state, cmd command) error {
+	provider, err := newMigrationProvider(s.conn, s.driver)
+	if err != nil {
+		return err
+	}
+
+	switch cmd.args[0] {
+	case "up":
+		results, err := provider.Up(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if len(results) == 0 {
+			fmt.Println("The database schema is already up to date")
+			return nil
+		}
+
+		for _, result := range results {
+			fmt.Printf("Applied %s (%s)\n", result.Source.Path, result.Duration)
+		}
+	case "down":
+		result, err := provider.Down(context.Background())
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Rolled back %s (%s)\n", result.Source.Path, result.Duration)
+	case "status":
+		statuses, err := provider.Status(context.Background())
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			if status.State == goose.StateApplied {
+				fmt.Printf("* %s: applied at %s\n", status.Source.Path, status.AppliedAt.Format(time.DateTime))
+			} else {
+				fmt.Printf("* %s: %s\n", status.Source.Path, status.State)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", cmd.args[0])
+	}
+
+	return nil
+}
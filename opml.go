@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type OPML struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Head    OPMLHead      `xml:"head"`
+	Body    []OPMLOutline `xml:"body>outline"`
+}
+
+type OPMLHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+	OwnerName   string `xml:"ownerName,omitempty"`
+}
+
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// OPMLFeed is a feed subscription found in an OPML document, along with the
+// folder path of the outlines it was nested in.
+type OPMLFeed struct {
+	Name    string
+	URL     string
+	SiteURL string
+	Folder  string
+}
+
+func readOPML(path string) (*OPML, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var doc OPML
+	decoder := xml.NewDecoder(file)
+	decoder.Strict = false
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid OPML file: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// feeds flattens the outline tree into the list of subscriptions it contains.
+// Outlines without an xmlUrl are treated as folders; nested folders are
+// joined with a slash.
+func (o *OPML) feeds() []OPMLFeed {
+	var feeds []OPMLFeed
+
+	var walk func(outlines []OPMLOutline, folder string)
+	walk = func(outlines []OPMLOutline, folder string) {
+		for _, outline := range outlines {
+			name := strings.TrimSpace(outline.Title)
+			if name == "" {
+				name = strings.TrimSpace(outline.Text)
+			}
+
+			if outline.XMLURL == "" {
+				if len(outline.Outlines) == 0 {
+					continue
+				}
+
+				subfolder := name
+				if folder != "" && name != "" {
+					subfolder = folder + "/" + name
+				} else if name == "" {
+					subfolder = folder
+				}
+				walk(outline.Outlines, subfolder)
+				continue
+			}
+
+			feeds = append(feeds, OPMLFeed{
+				Name:   name,
+				URL:    strings.TrimSpace(outline.XMLURL),
+				Folder: folder,
+			})
+		}
+	}
+	walk(o.Body, "")
+
+	return feeds
+}
+
+// newOPML builds an OPML 2.0 document from a list of subscriptions, nesting
+// each feed under outlines for its folder path.
+func newOPML(title string, feeds []OPMLFeed) *OPML {
+	doc := &OPML{
+		Version: "2.0",
+		Head: OPMLHead{
+			Title:       title,
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, feed := range feeds {
+		outlines := &doc.Body
+		if feed.Folder != "" {
+			for _, name := range strings.Split(feed.Folder, "/") {
+				outlines = findOrAddFolder(outlines, name)
+			}
+		}
+
+		*outlines = append(*outlines, OPMLOutline{
+			Text:    feed.Name,
+			Title:   feed.Name,
+			Type:    "rss",
+			XMLURL:  feed.URL,
+			HTMLURL: feed.SiteURL,
+		})
+	}
+
+	return doc
+}
+
+func findOrAddFolder(outlines *[]OPMLOutline, name string) *[]OPMLOutline {
+	for i := range *outlines {
+		outline := &(*outlines)[i]
+		if outline.XMLURL == "" && outline.Text == name {
+			return &outline.Outlines
+		}
+	}
+
+	*outlines = append(*outlines, OPMLOutline{Text: name, Title: name})
+	return &(*outlines)[len(*outlines)-1].Outlines
+}
+
+func (o *OPML) write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(o); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func validateFeedURL(feedURL string) error {
+	parsed, err := url.Parse(feedURL)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", parsed.Scheme)
+	}
+
+	if parsed.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thihxm/gator/internal/database"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+// outputFormat is the value of the --output flag of listing commands.
+type outputFormat string
+
+func (f *outputFormat) String() string {
+	return string(*f)
+}
+
+func (f *outputFormat) Set(value string) error {
+	switch value {
+	case outputTable, outputJSON, outputCSV:
+		*f = outputFormat(value)
+		return nil
+	default:
+		return fmt.Errorf("expected %s, %s or %s", outputTable, outputJSON, outputCSV)
+	}
+}
+
+// printListing prints data, a struct or a slice of structs, in the format
+// chosen with --output. JSON and CSV use the json tags of the struct fields
+// as keys and column names; the table format is left to printTable, since
+// every command lays out its own.
+func printListing(cmd command, data any, printTable func() error) error {
+	switch cmd.output {
+	case outputJSON:
+		return writeJSON(os.Stdout, data)
+	case outputCSV:
+		return writeCSV(os.Stdout, data)
+	default:
+		return printTable()
+	}
+}
+
+func writeJSON(w io.Writer, data any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(data)
+}
+
+// writeCSV writes a header with the column names of data followed by a
+// record for each struct in it.
+func writeCSV(w io.Writer, data any) error {
+	rows := reflect.ValueOf(data)
+	if rows.Kind() != reflect.Slice {
+		rows = reflect.Append(reflect.MakeSlice(reflect.SliceOf(rows.Type()), 0, 1), rows)
+	}
+
+	rowType := rows.Type().Elem()
+	var header []string
+	var fields []int
+	for i := range rowType.NumField() {
+		name, _, _ := strings.Cut(rowType.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for i := range rows.Len() {
+		record := make([]string, len(fields))
+		for j, field := range fields {
+			record[j] = csvValue(rows.Index(i).Field(field))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvValue formats a field for CSV. Missing values are left empty and lists
+// are separated by semicolons.
+func csvValue(value reflect.Value) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []string:
+		return strings.Join(v, ";")
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// nullStringPtr and nullTimePtr turn nullable columns into pointers, which
+// are written as null in JSON.
+func nullStringPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func nullTimePtr(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
+
+type userRow struct {
+	Name      string    `json:"name"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type feedRow struct {
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	CreatedBy     *string    `json:"created_by"`
+	SiteLink      *string    `json:"site_link"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// addedFeedRow is printed by addfeed. Added is false if the feed already
+// existed, and Followed is false if the user already followed it.
+type addedFeedRow struct {
+	Name     string  `json:"name"`
+	URL      string  `json:"url"`
+	SiteLink *string `json:"site_link"`
+	Added    bool    `json:"added"`
+	Followed bool    `json:"followed"`
+}
+
+type followingRow struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	SiteLink *string  `json:"site_link"`
+	Tags     []string `json:"tags"`
+}
+
+type tagRow struct {
+	Name      string `json:"name"`
+	FeedCount int64  `json:"feed_count"`
+}
+
+type postRow struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Feed        string     `json:"feed"`
+	URL         string     `json:"url"`
+	Description *string    `json:"description"`
+	PublishedAt *time.Time `json:"published_at"`
+	FetchedAt   time.Time  `json:"fetched_at"`
+	Cursor      string     `json:"cursor,omitempty"`
+}
+
+func newPostRow(post database.Post, feedName string) postRow {
+	return postRow{
+		ID:          post.ID,
+		Title:       post.Title,
+		Feed:        feedName,
+		URL:         post.Url,
+		Description: nullStringPtr(post.Description),
+		PublishedAt: nullTimePtr(post.PublishedAt),
+		FetchedAt:   post.CreatedAt,
+	}
+}
+
+type searchResultRow struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Feed        string     `json:"feed"`
+	URL         string     `json:"url"`
+	Snippet     string     `json:"snippet"`
+	PublishedAt *time.Time `json:"published_at"`
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/net/html"
+	"golang.org/x/term"
+)
+
+// defaultTerminalWidth is used when stdout isn't a terminal and COLUMNS isn't
+// set.
+const defaultTerminalWidth = 80
+
+// minColumnWidth is how narrow a table column can get before the table is
+// allowed to overflow the terminal.
+const minColumnWidth = 10
+
+// descriptionLines is how many lines of a post's description lists show.
+const descriptionLines = 3
+
+const (
+	styleBold  = "1"
+	styleDim   = "2"
+	styleCyan  = "36"
+	styleGreen = "32"
+)
+
+// terminal describes where output is written: how wide it is and whether it
+// understands colors.
+type terminal struct {
+	width int
+	color bool
+}
+
+// stdoutTerminal describes stdout. Colors are only used when it's a
+// terminal, unless NO_COLOR is set.
+func stdoutTerminal() terminal {
+	t := terminal{width: defaultTerminalWidth}
+
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		t.width = columns
+	}
+
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return t
+	}
+
+	if width, _, err := term.GetSize(fd); err == nil && width > 0 {
+		t.width = width
+	}
+	_, noColor := os.LookupEnv("NO_COLOR")
+	t.color = !noColor && os.Getenv("TERM") != "dumb"
+
+	return t
+}
+
+// style wraps s in the ANSI escape codes for code when colors are enabled.
+func (t terminal) style(code, s string) string {
+	if !t.color || code == "" || s == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// table lays out rows in aligned columns under a header.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (tb *table) append(row ...string) {
+	tb.rows = append(tb.rows, row)
+}
+
+// render writes the table, shrinking its widest columns and truncating their
+// cells when it doesn't fit in the terminal.
+func (tb *table) render(w io.Writer, t terminal) {
+	const gap = "  "
+
+	widths := make([]int, len(tb.header))
+	for _, row := range append([][]string{tb.header}, tb.rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], runewidth.StringWidth(cell))
+		}
+	}
+
+	total := func() int {
+		sum := len(gap) * (len(widths) - 1)
+		for _, width := range widths {
+			sum += width
+		}
+		return sum
+	}
+	for total() > t.width {
+		widest := 0
+		for i, width := range widths {
+			if width > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			break
+		}
+		widths[widest] -= min(total()-t.width, widths[widest]-minColumnWidth)
+	}
+
+	writeRow := func(row []string, code string) {
+		var b strings.Builder
+		for i, cell := range row {
+			cell = runewidth.Truncate(cell, widths[i], "…")
+			if i < len(row)-1 {
+				cell = runewidth.FillRight(cell, widths[i])
+			}
+			if i > 0 {
+				b.WriteString(gap)
+			}
+			b.WriteString(t.style(code, cell))
+		}
+		fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+	}
+
+	writeRow(tb.header, styleBold)
+	for _, row := range tb.rows {
+		writeRow(row, "")
+	}
+}
+
+// wrap breaks text into lines no wider than width, splitting words that
+// don't fit on a line of their own.
+func wrap(text string, width int) []string {
+	width = max(width, 1)
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	for _, word := range strings.Fields(text) {
+		wordWidth := runewidth.StringWidth(word)
+
+		if lineWidth > 0 && lineWidth+1+wordWidth > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineWidth = 0
+		}
+
+		for wordWidth > width {
+			head := runewidth.Truncate(word, width, "")
+			if head == "" {
+				_, size := utf8.DecodeRuneInString(word)
+				head = word[:size]
+			}
+			lines = append(lines, head)
+			word = word[len(head):]
+			wordWidth = runewidth.StringWidth(word)
+		}
+
+		if lineWidth > 0 {
+			line.WriteByte(' ')
+			lineWidth++
+		}
+		line.WriteString(word)
+		lineWidth += wordWidth
+	}
+	if lineWidth > 0 {
+		lines = append(lines, line.String())
+	}
+
+	return lines
+}
+
+// truncateLines keeps the first n lines, marking the last one with an
+// ellipsis if any were dropped.
+func truncateLines(lines []string, n, width int) []string {
+	if len(lines) <= n {
+		return lines
+	}
+
+	lines = lines[:n]
+	last := runewidth.Truncate(lines[n-1], width-1, "")
+	lines[n-1] = last + "…"
+	return lines
+}
+
+// blockElements start on a new line when HTML is turned into text.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "ul": true, "ol": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true, "table": true, "tr": true, "hr": true,
+	"section": true, "article": true, "figure": true, "figcaption": true,
+}
+
+// htmlToText returns the text of an HTML fragment, with entities decoded and
+// scripts and styles dropped. Block elements are separated by blank lines
+// and whitespace within them is collapsed.
+func htmlToText(fragment string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+
+	var blocks []string
+	var block strings.Builder
+	endBlock := func() {
+		if text := strings.Join(strings.Fields(block.String()), " "); text != "" {
+			blocks = append(blocks, text)
+		}
+		block.Reset()
+	}
+
+	skip := 0
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			endBlock()
+			return strings.Join(blocks, "\n\n")
+		case html.TextToken:
+			if skip == 0 {
+				block.Write(tokenizer.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+
+			switch {
+			case tag == "script" || tag == "style":
+				if tokenType == html.EndTagToken {
+					skip = max(skip-1, 0)
+				} else {
+					skip++
+				}
+			case blockElements[tag]:
+				endBlock()
+			}
+		}
+	}
+}
+
+// plainText turns an HTML fragment into a single line of text.
+func plainText(fragment string) string {
+	return strings.Join(strings.Fields(htmlToText(fragment)), " ")
+}
+
+// printPostList writes posts one after the other: the short ID next to the
+// title, then the feed and publication date, the link, and the first lines
+// of the description.
+func printPostList(w io.Writer, t terminal, posts []postRow) {
+	indent := strings.Repeat(" ", shortPostIDLength+2)
+	width := max(t.width-len(indent), minColumnWidth)
+
+	for i, post := range posts {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		title := runewidth.Truncate(plainText(post.Title), width, "…")
+		fmt.Fprintf(w, "%s  %s\n", t.style(styleGreen, shortPostID(post.ID)), t.style(styleBold, title))
+
+		meta := post.Feed
+		if post.PublishedAt != nil {
+			meta += " · " + post.PublishedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s%s\n", indent, t.style(styleDim, runewidth.Truncate(meta, width, "…")))
+		fmt.Fprintf(w, "%s%s\n", indent, t.style(styleCyan, post.URL))
+
+		if post.Description != nil {
+			lines := truncateLines(wrap(plainText(*post.Description), width), descriptionLines, width)
+			for _, line := range lines {
+				fmt.Fprintf(w, "%s%s\n", indent, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-runewidth"
+)
+
+func TestHTMLToText(t *testing.T) {
+	got := htmlToText(`<p>Hello &amp; <b>welcome</b>
+		to the   blog.</p><script>alert(1)</script><ul><li>One</li><li>Two<br>lines</li></ul>`)
+	want := "Hello & welcome to the blog.\n\nOne\n\nTwo\n\nlines"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := plainText("Just text, 1 < 2"); got != "Just text, 1 < 2" {
+		t.Errorf("plain text was changed: %q", got)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap("the quick brown fox jumps over the lazy dog", 10)
+	if strings.Join(lines, "|") != "the quick|brown fox|jumps over|the lazy|dog" {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+
+	// Wide runes take two columns and are never split.
+	for _, line := range wrap("日本語のテキストです", 5) {
+		if runewidth.StringWidth(line) > 5 {
+			t.Errorf("line %q is wider than 5 columns", line)
+		}
+	}
+
+	lines = truncateLines(wrap("one two three four five six", 9), 2, 9)
+	if strings.Join(lines, "|") != "one two|three…" {
+		t.Errorf("unexpected truncated lines: %q", lines)
+	}
+}
+
+func TestTableRender(t *testing.T) {
+	tb := table{header: []string{"NAME", "URL"}}
+	tb.append("Go Blog", "https://example.com/a/very/long/feed/url/that/does/not/fit")
+	tb.append("日本語ブログ", "https://example.jp")
+
+	var b strings.Builder
+	tb.render(&b, terminal{width: 40})
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got:\n%s", b.String())
+	}
+	for _, line := range lines {
+		if runewidth.StringWidth(line) > 40 {
+			t.Errorf("line %q is wider than the terminal", line)
+		}
+	}
+	if !strings.HasPrefix(lines[1], "Go Blog       https://example.com/") || !strings.HasSuffix(lines[1], "…") {
+		t.Errorf("expected the long URL to be truncated: %q", lines[1])
+	}
+	if lines[2] != "日本語ブログ  https://example.jp" {
+		t.Errorf("expected wide names to be aligned: %q", lines[2])
+	}
+	if strings.Contains(b.String(), "\x1b[") {
+		t.Error("expected no colors")
+	}
+
+	b.Reset()
+	tb.render(&b, terminal{width: 80, color: true})
+	if !strings.HasPrefix(b.String(), "\x1b[1mNAME") {
+		t.Errorf("expected a bold header: %q", b.String())
+	}
+}
+
+func TestPrintPostList(t *testing.T) {
+	description := "<p>Ünïcödé " + strings.Repeat("wörds ", 60) + "</p>"
+	published := time.Date(2024, 5, 1, 12, 30, 0, 0, time.Local)
+
+	var b strings.Builder
+	printPostList(&b, terminal{width: 40}, []postRow{{
+		ID:          uuid.MustParse("0123abcd-0000-0000-0000-000000000000"),
+		Title:       "Caf&eacute; <em>news</em>",
+		Feed:        "Blog",
+		URL:         "https://example.com/1",
+		Description: &description,
+		PublishedAt: &published,
+	}})
+
+	out := b.String()
+	assertContains(t, out,
+		"0123abcd  Café news\n",
+		"          Blog · 2024-05-01 12:30\n",
+		"          https://example.com/1\n",
+		"          Ünïcödé wörds",
+	)
+	assertNotContains(t, out, "<p>")
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 3+descriptionLines || !strings.HasSuffix(lines[len(lines)-1], "…") {
+		t.Errorf("expected the description to be cut to %d lines:\n%s", descriptionLines, out)
+	}
+	for _, line := range lines {
+		if runewidth.StringWidth(line) > 40 {
+			t.Errorf("line %q is wider than the terminal", line)
+		}
+	}
+}
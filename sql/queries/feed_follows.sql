@@ -12,7 +12,7 @@ WITH inserted_feed_follow AS (
 )
 SELECT
     inserted_feed_follow.*,
-    feeds.name AS feed_name,
+    COALESCE(inserted_feed_follow.display_name, feeds.name)::text AS feed_name,
     users.name AS user_name
 FROM inserted_feed_follow
 INNER JOIN users ON inserted_feed_follow.user_id = users.id
@@ -20,10 +20,44 @@ INNER JOIN feeds ON inserted_feed_follow.feed_id = feeds.id;
 
 
 -- name: GetFeedFollowsForUser :many
-SELECT feeds.name as feed_name, users.name as user_name FROM feed_follows
+SELECT
+    COALESCE(feed_follows.display_name, feeds.name)::text as feed_name,
+    feeds.url as feed_url,
+    feeds.site_link as feed_site_link,
+    users.name as user_name,
+    COALESCE(
+        array_agg(tags.name ORDER BY tags.name) FILTER (WHERE tags.name IS NOT NULL),
+        '{}'
+    )::text[] AS tags
+FROM feed_follows
 INNER JOIN users ON feed_follows.user_id = users.id
 INNER JOIN feeds ON feed_follows.feed_id = feeds.id
-WHERE feed_follows.user_id = $1;
+LEFT JOIN feed_follow_tags ON feed_follows.id = feed_follow_tags.feed_follow_id
+LEFT JOIN tags ON feed_follow_tags.tag_id = tags.id
+WHERE
+    feed_follows.user_id = sqlc.arg(user_id)
+    AND (
+        sqlc.narg(tag)::text IS NULL
+        OR EXISTS (
+            SELECT 1 FROM feed_follow_tags AS tagged
+            INNER JOIN tags AS filter_tags ON tagged.tag_id = filter_tags.id
+            WHERE tagged.feed_follow_id = feed_follows.id
+                AND filter_tags.name = sqlc.narg(tag)
+        )
+    )
+GROUP BY feed_follows.id, feeds.id, users.id
+ORDER BY feed_name;
+
+
+-- name: GetFeedFollow :one
+SELECT * FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2;
+
+
+-- name: GetFeedFollowByUrl :one
+SELECT feed_follows.* FROM feed_follows
+INNER JOIN feeds ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1 AND feeds.url = $2;
 
 
 -- name: DeleteFeedFollow :exec
@@ -33,3 +67,38 @@ WHERE
     AND feed_follows.feed_id = (
         SELECT id FROM feeds WHERE url = $2
     );
+
+
+-- name: GetNextFeedFollower :one
+SELECT feed_follows.user_id FROM feed_follows
+WHERE feed_follows.feed_id = $1 AND feed_follows.user_id <> $2
+ORDER BY feed_follows.created_at ASC
+LIMIT 1;
+
+
+-- name: SetFeedFollowDisplayName :execrows
+UPDATE feed_follows
+SET
+    display_name = $3,
+    updated_at = $4
+WHERE
+    feed_follows.user_id = $1
+    AND feed_follows.feed_id = (
+        SELECT id FROM feeds WHERE url = $2
+    );
+
+
+-- name: SetFeedFollowRetention :one
+UPDATE feed_follows
+SET
+    retention_max_age_seconds = $2,
+    retention_max_posts = $3,
+    updated_at = $4
+WHERE
+    id = $1
+RETURNING *;
+
+
+-- name: GetFeedFollowRetentions :many
+SELECT retention_max_age_seconds, retention_max_posts FROM feed_follows
+WHERE feed_id = $1;
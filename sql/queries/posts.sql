@@ -1,5 +1,5 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content)
 VALUES (
     $1,
     $2,
@@ -8,16 +8,165 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9
 )
 RETURNING *;
 
 
 -- name: GetPostsForUser :many
-SELECT posts.* FROM posts
+SELECT
+    sqlc.embed(posts),
+    COALESCE(feed_follows.display_name, feeds.name)::text AS feed_name,
+    EXISTS (
+        SELECT 1 FROM post_reads
+        WHERE post_reads.user_id = feed_follows.user_id
+            AND post_reads.post_id = posts.id
+    ) AS is_read,
+    EXISTS (
+        SELECT 1 FROM saved_posts
+        WHERE saved_posts.user_id = feed_follows.user_id
+            AND saved_posts.post_id = posts.id
+    ) AS is_starred
+FROM posts
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
-INNER JOIN feeds ON posts.feed_id = feed_follows.feed_id
-WHERE feed_follows.user_id = $1
-ORDER BY posts.created_at DESC
+INNER JOIN feeds ON posts.feed_id = feeds.id
+CROSS JOIN LATERAL (
+    SELECT
+        CASE
+            WHEN sqlc.arg(sort_by)::text = 'published'
+                THEN COALESCE(posts.published_at, posts.created_at)
+            ELSE posts.created_at
+        END AS sort_at
+) AS sort_key
+WHERE
+    feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(feed_url)::text IS NULL OR feeds.url = sqlc.narg(feed_url))
+    AND (
+        sqlc.narg(tag)::text IS NULL
+        OR EXISTS (
+            SELECT 1 FROM feed_follow_tags
+            INNER JOIN tags ON feed_follow_tags.tag_id = tags.id
+            WHERE feed_follow_tags.feed_follow_id = feed_follows.id
+                AND tags.name = sqlc.narg(tag)
+        )
+    )
+    AND (
+        sqlc.narg(is_read)::boolean IS NULL
+        OR EXISTS (
+            SELECT 1 FROM post_reads
+            WHERE post_reads.user_id = feed_follows.user_id
+                AND post_reads.post_id = posts.id
+        ) = sqlc.narg(is_read)
+    )
+    AND (sqlc.narg(since)::timestamptz IS NULL OR sort_key.sort_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamptz IS NULL OR sort_key.sort_at < sqlc.narg(until))
+    AND (
+        sqlc.narg(cursor_at)::timestamptz IS NULL
+        OR (sort_key.sort_at, posts.id) < (sqlc.narg(cursor_at), sqlc.narg(cursor_id)::uuid)
+    )
+ORDER BY
+    sort_key.sort_at DESC,
+    posts.id DESC
+LIMIT sqlc.arg(max_posts);
+
+
+-- name: GetPostsForFeed :many
+SELECT sqlc.embed(posts), feeds.name AS feed_name FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feeds.url = $1
+ORDER BY posts.published_at DESC NULLS LAST, posts.created_at DESC
 LIMIT $2;
 
+
+-- name: GetPostByUrl :one
+SELECT * FROM posts WHERE url = $1;
+
+
+-- name: GetPostsByIDPrefix :many
+SELECT posts.* FROM posts
+WHERE
+    posts.id::text LIKE sqlc.arg(prefix)::text || '%'
+    AND (
+        EXISTS (
+            SELECT 1 FROM feed_follows
+            WHERE feed_follows.feed_id = posts.feed_id
+                AND feed_follows.user_id = sqlc.arg(user_id)
+        )
+        OR EXISTS (
+            SELECT 1 FROM saved_posts
+            WHERE saved_posts.post_id = posts.id
+                AND saved_posts.user_id = sqlc.arg(user_id)
+        )
+    )
+LIMIT 2;
+
+
+-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    COALESCE(feed_follows.display_name, feeds.name)::text AS feed_name,
+    ts_rank(
+        posts.search_vector,
+        websearch_to_tsquery('english', sqlc.arg(query))
+    )::real AS rank,
+    ts_headline(
+        'english',
+        coalesce(posts.description, '') || ' ' || coalesce(posts.content, ''),
+        websearch_to_tsquery('english', sqlc.arg(query)),
+        'StartSel=**, StopSel=**, MaxWords=30, MinWords=10, MaxFragments=2'
+    )::text AS snippet
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE
+    feed_follows.user_id = sqlc.arg(user_id)
+    AND posts.search_vector @@ websearch_to_tsquery('english', sqlc.arg(query))
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT sqlc.arg(max_posts);
+
+
+-- name: GetPrunablePosts :many
+SELECT candidates.id, feeds.name AS feed_name, feeds.url AS feed_url FROM (
+    SELECT
+        posts.id,
+        posts.feed_id,
+        COALESCE(posts.published_at, posts.created_at) AS posted_at,
+        ROW_NUMBER() OVER (
+            PARTITION BY posts.feed_id
+            ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC
+        ) AS position
+    FROM posts
+    WHERE NOT EXISTS (
+        SELECT 1 FROM saved_posts WHERE saved_posts.post_id = posts.id
+    )
+) AS candidates
+INNER JOIN feeds ON candidates.feed_id = feeds.id
+-- Feeds nobody follows get a single row with the global policy.
+LEFT JOIN feed_follows ON candidates.feed_id = feed_follows.feed_id
+GROUP BY candidates.id, feeds.name, feeds.url, candidates.posted_at
+HAVING bool_and(
+    COALESCE(
+        candidates.posted_at < sqlc.arg(now)::timestamptz - INTERVAL '1 second' * NULLIF(COALESCE(
+            feed_follows.retention_max_age_seconds,
+            sqlc.narg(default_max_age_seconds)::bigint
+        ), 0),
+        false
+    )
+    OR COALESCE(
+        candidates.position > NULLIF(COALESCE(
+            feed_follows.retention_max_posts,
+            sqlc.narg(default_max_posts)::integer
+        ), 0),
+        false
+    )
+)
+ORDER BY feeds.name, candidates.posted_at;
+
+
+-- name: DeletePosts :execrows
+DELETE FROM posts WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
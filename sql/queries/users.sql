@@ -18,6 +18,10 @@ WHERE name = $1;
 DELETE FROM users;
 
 
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1;
+
+
 -- name: GetUsers :many
 SELECT * FROM users;
 
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN site_link TEXT,
+    ADD COLUMN description TEXT,
+    ADD COLUMN language TEXT,
+    ADD COLUMN image_url TEXT,
+    ADD COLUMN generator TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN site_link,
+    DROP COLUMN description,
+    DROP COLUMN language,
+    DROP COLUMN image_url,
+    DROP COLUMN generator;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE saved_posts(
+    user_id UUID NOT NULL,
+    post_id UUID NOT NULL,
+    saved_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, post_id),
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (post_id)
+        REFERENCES posts(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE saved_posts;
\ No newline at end of file
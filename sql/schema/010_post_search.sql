@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE posts
+    ADD COLUMN content TEXT;
+
+ALTER TABLE posts
+    ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(content, '')), 'C')
+    ) STORED;
+
+CREATE INDEX idx_posts_search_vector ON posts USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX idx_posts_search_vector;
+
+ALTER TABLE posts
+    DROP COLUMN search_vector;
+
+ALTER TABLE posts
+    DROP COLUMN content;
\ No newline at end of file
@@ -0,0 +1,55 @@
+-- +goose Up
+CREATE TABLE tags(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT uc_user_tag
+    UNIQUE(user_id, name)
+);
+
+CREATE TABLE feed_follow_tags(
+    feed_follow_id UUID NOT NULL,
+    tag_id UUID NOT NULL,
+    PRIMARY KEY (feed_follow_id, tag_id),
+    FOREIGN KEY (feed_follow_id)
+        REFERENCES feed_follows(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (tag_id)
+        REFERENCES tags(id)
+        ON DELETE CASCADE
+);
+
+INSERT INTO tags (id, created_at, updated_at, user_id, name)
+SELECT gen_random_uuid(), NOW(), NOW(), user_id, folder
+FROM feed_follows
+WHERE folder IS NOT NULL
+GROUP BY user_id, folder;
+
+INSERT INTO feed_follow_tags (feed_follow_id, tag_id)
+SELECT feed_follows.id, tags.id
+FROM feed_follows
+INNER JOIN tags
+    ON tags.user_id = feed_follows.user_id
+    AND tags.name = feed_follows.folder;
+
+ALTER TABLE feed_follows
+    DROP COLUMN folder;
+
+-- +goose Down
+ALTER TABLE feed_follows
+    ADD COLUMN folder TEXT;
+
+UPDATE feed_follows
+SET folder = (
+    SELECT MIN(tags.name) FROM tags
+    INNER JOIN feed_follow_tags ON tags.id = feed_follow_tags.tag_id
+    WHERE feed_follow_tags.feed_follow_id = feed_follows.id
+);
+
+DROP TABLE feed_follow_tags;
+DROP TABLE tags;
\ No newline at end of file
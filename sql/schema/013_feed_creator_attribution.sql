@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE feeds
+    DROP CONSTRAINT fk_user_id;
+
+ALTER TABLE feeds
+    ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE feeds
+    ADD CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE SET NULL;
+
+-- +goose Down
+DELETE FROM feeds WHERE user_id IS NULL;
+
+ALTER TABLE feeds
+    DROP CONSTRAINT fk_user_id;
+
+ALTER TABLE feeds
+    ALTER COLUMN user_id SET NOT NULL;
+
+ALTER TABLE feeds
+    ADD CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE;
\ No newline at end of file
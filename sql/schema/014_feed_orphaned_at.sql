@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+    ADD COLUMN orphaned_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE feeds
+SET orphaned_at = NOW()
+WHERE NOT EXISTS (
+    SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id
+);
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN orphaned_at;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +goose Up
+-- NULL uses the global setting and 0 keeps posts forever.
+ALTER TABLE feed_follows
+    ADD COLUMN retention_max_age_seconds BIGINT,
+    ADD COLUMN retention_max_posts INTEGER;
+
+-- +goose Down
+ALTER TABLE feed_follows
+    DROP COLUMN retention_max_age_seconds,
+    DROP COLUMN retention_max_posts;
\ No newline at end of file
@@ -0,0 +1,140 @@
+-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6
+)
+RETURNING *;
+
+
+-- name: GetFeeds :many
+SELECT feeds.*, users.name as user_name FROM feeds
+LEFT JOIN users ON feeds.user_id = users.id;
+
+
+-- name: GetFeedsOwnedByUser :many
+SELECT * FROM feeds WHERE user_id = ?1;
+
+
+-- name: GetFeedByUrl :one
+SELECT * FROM feeds WHERE url = ?1;
+
+
+-- name: MarkFeedFetched :one
+UPDATE feeds
+SET
+    last_fetched_at = ?2,
+    updated_at = ?3
+WHERE
+    id = ?1
+RETURNING *;
+
+
+-- name: UpdateFeedMetadata :one
+UPDATE feeds
+SET
+    site_link = ?2,
+    description = ?3,
+    language = ?4,
+    image_url = ?5,
+    generator = ?6,
+    updated_at = ?7
+WHERE
+    id = ?1
+RETURNING *;
+
+
+-- name: RenameFeed :one
+UPDATE feeds
+SET
+    name = ?2,
+    updated_at = ?3
+WHERE
+    id = ?1
+RETURNING *;
+
+
+-- name: SetFeedUrl :one
+UPDATE feeds
+SET
+    url = ?2,
+    last_fetched_at = NULL,
+    updated_at = ?3
+WHERE
+    id = ?1
+RETURNING *;
+
+
+-- name: SetFeedOwner :exec
+UPDATE feeds
+SET
+    user_id = ?2,
+    updated_at = ?3
+WHERE
+    id = ?1;
+
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = ?1;
+
+
+-- name: ResetFeeds :exec
+DELETE FROM feeds;
+
+
+-- name: GetNextFeedToFetch :one
+SELECT * FROM feeds
+WHERE EXISTS (
+    SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id
+)
+ORDER BY
+    last_fetched_at ASC NULLS FIRST
+LIMIT 1;
+
+
+-- name: MarkOrphanedFeeds :execrows
+UPDATE feeds
+SET orphaned_at = ?1
+WHERE
+    orphaned_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id
+    );
+
+
+-- name: ClearFollowedFeedsOrphanedAt :execrows
+UPDATE feeds
+SET orphaned_at = NULL
+WHERE
+    orphaned_at IS NOT NULL
+    AND EXISTS (
+        SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id
+    );
+
+
+-- name: GetFeedsOrphanedBefore :many
+SELECT feeds.*, COUNT(posts.id) AS post_count FROM feeds
+LEFT JOIN posts ON posts.feed_id = feeds.id
+WHERE feeds.orphaned_at < ?1
+    AND NOT EXISTS (
+        SELECT 1 FROM posts AS starred_posts
+        INNER JOIN saved_posts ON saved_posts.post_id = starred_posts.id
+        WHERE starred_posts.feed_id = feeds.id
+    )
+GROUP BY feeds.id
+ORDER BY feeds.orphaned_at;
+
+
+-- name: DeleteFeedsOrphanedBefore :execrows
+DELETE FROM feeds
+WHERE
+    orphaned_at < ?1
+    AND NOT EXISTS (
+        SELECT 1 FROM posts AS starred_posts
+        INNER JOIN saved_posts ON saved_posts.post_id = starred_posts.id
+        WHERE starred_posts.feed_id = feeds.id
+    );
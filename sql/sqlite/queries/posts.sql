@@ -0,0 +1,190 @@
+-- name: CreatePost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, content)
+VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6,
+    ?7,
+    ?8,
+    ?9
+)
+RETURNING *, NULL AS search_vector;
+
+
+-- name: GetPostsForUser :many
+SELECT
+    user_posts.id,
+    user_posts.created_at,
+    user_posts.updated_at,
+    user_posts.title,
+    user_posts.url,
+    user_posts.description,
+    user_posts.published_at,
+    user_posts.feed_id,
+    user_posts.content,
+    NULL AS search_vector,
+    user_posts.feed_name,
+    user_posts.is_read,
+    user_posts.is_starred
+FROM (
+    SELECT
+        posts.*,
+        COALESCE(feed_follows.display_name, feeds.name) AS feed_name,
+        EXISTS (
+            SELECT 1 FROM post_reads
+            WHERE post_reads.user_id = feed_follows.user_id
+                AND post_reads.post_id = posts.id
+        ) AS is_read,
+        EXISTS (
+            SELECT 1 FROM saved_posts
+            WHERE saved_posts.user_id = feed_follows.user_id
+                AND saved_posts.post_id = posts.id
+        ) AS is_starred,
+        CASE
+            WHEN ?1 = 'published'
+                THEN COALESCE(posts.published_at, posts.created_at)
+            ELSE posts.created_at
+        END AS sort_at
+    FROM posts
+    INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+    INNER JOIN feeds ON posts.feed_id = feeds.id
+    WHERE
+        feed_follows.user_id = ?2
+        AND (?3 IS NULL OR feeds.url = ?3)
+        AND (
+            ?4 IS NULL
+            OR EXISTS (
+                SELECT 1 FROM feed_follow_tags
+                INNER JOIN tags ON feed_follow_tags.tag_id = tags.id
+                WHERE feed_follow_tags.feed_follow_id = feed_follows.id
+                    AND tags.name = ?4
+            )
+        )
+        AND (
+            ?5 IS NULL
+            OR EXISTS (
+                SELECT 1 FROM post_reads
+                WHERE post_reads.user_id = feed_follows.user_id
+                    AND post_reads.post_id = posts.id
+            ) = ?5
+        )
+) AS user_posts
+WHERE
+    (?6 IS NULL OR user_posts.sort_at >= ?6)
+    AND (?7 IS NULL OR user_posts.sort_at < ?7)
+    AND (
+        ?8 IS NULL
+        OR (user_posts.sort_at, user_posts.id) < (?8, ?9)
+    )
+ORDER BY
+    user_posts.sort_at DESC,
+    user_posts.id DESC
+LIMIT ?10;
+
+
+-- name: GetPostsForFeed :many
+SELECT
+    posts.id,
+    posts.created_at,
+    posts.updated_at,
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    posts.feed_id,
+    posts.content,
+    NULL AS search_vector,
+    feeds.name AS feed_name
+FROM posts
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feeds.url = ?1
+ORDER BY posts.published_at DESC NULLS LAST, posts.created_at DESC
+LIMIT ?2;
+
+
+-- name: GetPostByUrl :one
+SELECT *, NULL AS search_vector FROM posts WHERE url = ?1;
+
+
+-- name: GetPostsByIDPrefix :many
+SELECT posts.*, NULL AS search_vector FROM posts
+WHERE
+    posts.id LIKE ?1 || '%'
+    AND (
+        EXISTS (
+            SELECT 1 FROM feed_follows
+            WHERE feed_follows.feed_id = posts.feed_id
+                AND feed_follows.user_id = ?2
+        )
+        OR EXISTS (
+            SELECT 1 FROM saved_posts
+            WHERE saved_posts.post_id = posts.id
+                AND saved_posts.user_id = ?2
+        )
+    )
+LIMIT 2;
+
+
+-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    COALESCE(feed_follows.display_name, feeds.name) AS feed_name,
+    -bm25(posts_search, 10.0, 5.0, 1.0) AS rank,
+    snippet(posts_search, -1, '**', '**', '...', 30) AS snippet
+FROM posts_search
+INNER JOIN posts ON posts.rowid = posts_search.rowid
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+-- MatchQuery turns queries with nothing to search for into '', which FTS5
+-- can't parse.
+WHERE
+    ?1 != ''
+    AND posts_search MATCH ?1
+    AND feed_follows.user_id = ?2
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT ?3;
+
+
+-- name: GetPrunablePosts :many
+SELECT candidates.id, feeds.name AS feed_name, feeds.url AS feed_url FROM (
+    SELECT
+        posts.id,
+        posts.feed_id,
+        COALESCE(posts.published_at, posts.created_at) AS posted_at,
+        ROW_NUMBER() OVER (
+            PARTITION BY posts.feed_id
+            ORDER BY COALESCE(posts.published_at, posts.created_at) DESC, posts.id DESC
+        ) AS position
+    FROM posts
+    WHERE NOT EXISTS (
+        SELECT 1 FROM saved_posts WHERE saved_posts.post_id = posts.id
+    )
+) AS candidates
+INNER JOIN feeds ON candidates.feed_id = feeds.id
+LEFT JOIN feed_follows ON candidates.feed_id = feed_follows.feed_id
+GROUP BY candidates.id, feeds.name, feeds.url, candidates.posted_at
+HAVING MIN(
+    COALESCE(
+        candidates.posted_at < strftime(
+            '%Y-%m-%d %H:%M:%f',
+            ?1,
+            '-' || NULLIF(COALESCE(feed_follows.retention_max_age_seconds, ?2), 0) || ' seconds'
+        ),
+        0
+    )
+    OR COALESCE(
+        candidates.position > NULLIF(COALESCE(feed_follows.retention_max_posts, ?3), 0),
+        0
+    )
+) = 1
+ORDER BY feeds.name, candidates.posted_at;
+
+
+-- name: DeletePosts :execrows
+DELETE FROM posts WHERE id IN (SELECT value FROM json_each(?1));
@@ -0,0 +1,36 @@
+-- name: StarPost :exec
+INSERT INTO saved_posts (user_id, post_id, saved_at)
+VALUES (
+    ?1,
+    ?2,
+    ?3
+)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+
+-- name: UnstarPost :execrows
+DELETE FROM saved_posts
+WHERE user_id = ?1 AND post_id = ?2;
+
+
+-- name: GetStarredPostsForUser :many
+SELECT
+    posts.id,
+    posts.created_at,
+    posts.updated_at,
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    posts.feed_id,
+    posts.content,
+    NULL AS search_vector,
+    COALESCE(feed_follows.display_name, feeds.name) AS feed_name
+FROM posts
+INNER JOIN saved_posts ON posts.id = saved_posts.post_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+LEFT JOIN feed_follows
+    ON feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = saved_posts.user_id
+WHERE saved_posts.user_id = ?1
+ORDER BY saved_posts.saved_at DESC;
@@ -0,0 +1,48 @@
+-- name: UpsertTag :one
+INSERT INTO tags (id, created_at, updated_at, user_id, name)
+VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5
+)
+ON CONFLICT (user_id, name) DO UPDATE
+SET updated_at = excluded.updated_at
+RETURNING *;
+
+
+-- name: TagFeedFollow :exec
+INSERT INTO feed_follow_tags (feed_follow_id, tag_id)
+VALUES (
+    ?1,
+    ?2
+)
+ON CONFLICT (feed_follow_id, tag_id) DO NOTHING;
+
+
+-- name: UntagFeedFollow :execrows
+DELETE FROM feed_follow_tags
+WHERE
+    feed_follow_tags.feed_follow_id = ?1
+    AND feed_follow_tags.tag_id = (
+        SELECT id FROM tags WHERE user_id = ?2 AND name = ?3
+    );
+
+
+-- name: DeleteUnusedTags :exec
+DELETE FROM tags
+WHERE
+    tags.user_id = ?1
+    AND NOT EXISTS (
+        SELECT 1 FROM feed_follow_tags
+        WHERE feed_follow_tags.tag_id = tags.id
+    );
+
+
+-- name: GetTagsForUser :many
+SELECT tags.name, COUNT(feed_follow_tags.feed_follow_id) AS feed_count FROM tags
+LEFT JOIN feed_follow_tags ON tags.id = feed_follow_tags.tag_id
+WHERE tags.user_id = ?1
+GROUP BY tags.id
+ORDER BY tags.name;
@@ -0,0 +1,159 @@
+-- +goose Up
+-- SQLite equivalent of sql/schema up to 015_retention.sql. Columns are kept in
+-- the same order as in PostgreSQL, since the shared queries rely on it.
+CREATE TABLE users(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE feeds(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    url TEXT NOT NULL UNIQUE,
+    user_id TEXT,
+    last_fetched_at TIMESTAMP,
+    site_link TEXT,
+    description TEXT,
+    language TEXT,
+    image_url TEXT,
+    generator TEXT,
+    orphaned_at TIMESTAMP,
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE SET NULL
+);
+
+CREATE TABLE feed_follows(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    feed_id TEXT NOT NULL,
+    display_name TEXT,
+    retention_max_age_seconds INTEGER,
+    retention_max_posts INTEGER,
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (feed_id)
+        REFERENCES feeds(id)
+        ON DELETE CASCADE,
+    CONSTRAINT uc_user_feed
+    UNIQUE(user_id, feed_id)
+);
+
+CREATE TABLE posts(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    title TEXT NOT NULL,
+    url TEXT UNIQUE NOT NULL,
+    description TEXT,
+    published_at TIMESTAMP,
+    feed_id TEXT NOT NULL,
+    content TEXT,
+    FOREIGN KEY (feed_id)
+        REFERENCES feeds(id)
+        ON DELETE CASCADE
+);
+
+CREATE VIRTUAL TABLE posts_search USING fts5(
+    title,
+    description,
+    content,
+    content = 'posts',
+    content_rowid = 'rowid'
+);
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_search_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_search (rowid, title, description, content)
+    VALUES (new.rowid, new.title, new.description, new.content);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_search_delete AFTER DELETE ON posts BEGIN
+    INSERT INTO posts_search (posts_search, rowid, title, description, content)
+    VALUES ('delete', old.rowid, old.title, old.description, old.content);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_search_update AFTER UPDATE ON posts BEGIN
+    INSERT INTO posts_search (posts_search, rowid, title, description, content)
+    VALUES ('delete', old.rowid, old.title, old.description, old.content);
+    INSERT INTO posts_search (rowid, title, description, content)
+    VALUES (new.rowid, new.title, new.description, new.content);
+END;
+-- +goose StatementEnd
+
+CREATE TABLE post_reads(
+    user_id TEXT NOT NULL,
+    post_id TEXT NOT NULL,
+    read_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id),
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (post_id)
+        REFERENCES posts(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE saved_posts(
+    user_id TEXT NOT NULL,
+    post_id TEXT NOT NULL,
+    saved_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id),
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (post_id)
+        REFERENCES posts(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE tags(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    CONSTRAINT uc_user_tag
+    UNIQUE(user_id, name)
+);
+
+CREATE TABLE feed_follow_tags(
+    feed_follow_id TEXT NOT NULL,
+    tag_id TEXT NOT NULL,
+    PRIMARY KEY (feed_follow_id, tag_id),
+    FOREIGN KEY (feed_follow_id)
+        REFERENCES feed_follows(id)
+        ON DELETE CASCADE,
+    FOREIGN KEY (tag_id)
+        REFERENCES tags(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE feed_follow_tags;
+DROP TABLE tags;
+DROP TABLE saved_posts;
+DROP TABLE post_reads;
+DROP TRIGGER posts_search_update;
+DROP TRIGGER posts_search_delete;
+DROP TRIGGER posts_search_insert;
+DROP TABLE posts_search;
+DROP TABLE posts;
+DROP TABLE feed_follows;
+DROP TABLE feeds;
+DROP TABLE users;
\ No newline at end of file
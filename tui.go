@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/mattn/go-runewidth"
+	"github.com/thihxm/gator/internal/database"
+	"golang.org/x/term"
+)
+
+// readerPostLimit is how many posts the reader loads for a feed or tag.
+const readerPostLimit = 200
+
+const (
+	paneSidebar = iota
+	panePosts
+	panePreview
+)
+
+var (
+	paneStyle        = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("8"))
+	focusedPaneStyle = paneStyle.BorderForeground(lipgloss.Color("6"))
+	selectedStyle    = lipgloss.NewStyle().Reverse(true)
+	boldStyle        = lipgloss.NewStyle().Bold(true)
+	dimStyle         = lipgloss.NewStyle().Faint(true)
+	linkStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	unreadStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+)
+
+// readerHelp lists the key bindings in the status bar.
+const readerHelp = "tab pane · j/k move · enter read · n next unread · s star · o open · u unread only · R refresh · q quit"
+
+// source is an entry of the reader's sidebar: every followed post, the posts
+// of feeds with a tag, or those of a single feed.
+type source struct {
+	label   string
+	tag     string
+	feedURL string
+}
+
+// readerSourcesMsg, readerPostsMsg and readerErrMsg carry the results of the
+// queries the reader runs in the background. Posts loaded for a source that
+// is no longer selected are dropped.
+type readerSourcesMsg struct {
+	sources []source
+	err     error
+}
+
+type readerPostsMsg struct {
+	source  source
+	posts   []database.GetPostsForUserRow
+	refresh bool
+	err     error
+}
+
+type readerErrMsg struct {
+	err error
+}
+
+type readerTickMsg time.Time
+
+// reader is the model of the full-screen reader run by gator tui.
+type reader struct {
+	s       *state
+	user    database.User
+	refresh time.Duration
+	open    func(url string) error
+
+	sources []source
+	posts   []database.GetPostsForUserRow
+
+	focus         int
+	sourceIndex   int
+	sourceOffset  int
+	postIndex     int
+	postOffset    int
+	previewOffset int
+	unreadOnly    bool
+
+	width  int
+	height int
+	status string
+}
+
+type tuiOptions struct {
+	refresh time.Duration
+}
+
+// handlerTUI runs the full-screen reader until the user quits it.
+func handlerTUI(s *state, cmd command, user database.User, opts tuiOptions) error {
+	if opts.refresh <= 0 {
+		return fmt.Errorf("--refresh must be positive")
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("gator tui needs to run in a terminal")
+	}
+
+	_, err := tea.NewProgram(newReader(s, user, opts.refresh), tea.WithAltScreen()).Run()
+	return err
+}
+
+func newReader(s *state, user database.User, refresh time.Duration) *reader {
+	return &reader{
+		s:       s,
+		user:    user,
+		refresh: refresh,
+		open:    openInBrowser,
+		sources: []source{{label: "All posts"}},
+		focus:   panePosts,
+	}
+}
+
+func (m *reader) Init() tea.Cmd {
+	return tea.Batch(m.loadSources(), m.loadPosts(false), m.tick())
+}
+
+func (m *reader) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		cmd = m.handleKey(msg)
+	case readerSourcesMsg:
+		cmd = m.setSources(msg)
+	case readerPostsMsg:
+		m.setPosts(msg)
+	case readerErrMsg:
+		m.status = "Error: " + friendlyError(msg.err).Error()
+	case readerTickMsg:
+		cmd = tea.Batch(m.loadSources(), m.loadPosts(true), m.tick())
+	}
+
+	m.scroll()
+	return m, cmd
+}
+
+func (m *reader) handleKey(msg tea.KeyMsg) tea.Cmd {
+	m.status = ""
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "tab":
+		m.focus = (m.focus + 1) % 3
+	case "shift+tab":
+		m.focus = (m.focus + 2) % 3
+	case "j", "down":
+		return m.move(1)
+	case "k", "up":
+		return m.move(-1)
+	case "pgdown", " ":
+		m.previewOffset += m.paneHeight() / 2
+	case "pgup":
+		m.previewOffset = max(m.previewOffset-m.paneHeight()/2, 0)
+	case "enter":
+		if m.focus == paneSidebar {
+			m.focus = panePosts
+			return nil
+		}
+		m.focus = panePreview
+		return m.markRead()
+	case "n":
+		return m.nextUnread()
+	case "r":
+		return m.markRead()
+	case "s":
+		return m.toggleStar()
+	case "o":
+		return m.openPost()
+	case "u":
+		m.unreadOnly = !m.unreadOnly
+		m.postIndex = 0
+		return m.loadPosts(false)
+	case "R":
+		return tea.Batch(m.loadSources(), m.loadPosts(true))
+	}
+
+	return nil
+}
+
+// move moves the selection of the focused pane, or scrolls the preview.
+func (m *reader) move(delta int) tea.Cmd {
+	switch m.focus {
+	case paneSidebar:
+		index := min(max(m.sourceIndex+delta, 0), len(m.sources)-1)
+		if index == m.sourceIndex {
+			return nil
+		}
+		m.sourceIndex = index
+		m.postIndex = 0
+		m.posts = nil
+		return m.loadPosts(false)
+	case panePosts:
+		if len(m.posts) > 0 {
+			m.selectPost(min(max(m.postIndex+delta, 0), len(m.posts)-1))
+		}
+	case panePreview:
+		m.previewOffset = max(m.previewOffset+delta, 0)
+	}
+
+	return nil
+}
+
+func (m *reader) selectPost(index int) {
+	if index != m.postIndex {
+		m.previewOffset = 0
+	}
+	m.postIndex = index
+}
+
+func (m *reader) selected() (*database.GetPostsForUserRow, bool) {
+	if m.postIndex >= len(m.posts) {
+		return nil, false
+	}
+	return &m.posts[m.postIndex], true
+}
+
+// markRead marks the selected post as read, updating the list right away
+// rather than waiting for the query.
+func (m *reader) markRead() tea.Cmd {
+	row, ok := m.selected()
+	if !ok || row.IsRead {
+		return nil
+	}
+	row.IsRead = true
+
+	postID := row.Post.ID
+	return m.exec(func(db database.Querier) error {
+		return db.MarkPostRead(
+			context.Background(),
+			database.MarkPostReadParams{
+				UserID: m.user.ID,
+				PostID: postID,
+				ReadAt: time.Now(),
+			},
+		)
+	})
+}
+
+// nextUnread selects the next unread post after the selected one and marks
+// it as read.
+func (m *reader) nextUnread() tea.Cmd {
+	for i := m.postIndex + 1; i < len(m.posts); i++ {
+		if !m.posts[i].IsRead {
+			m.selectPost(i)
+			return m.markRead()
+		}
+	}
+
+	m.status = "No more unread posts"
+	return nil
+}
+
+func (m *reader) toggleStar() tea.Cmd {
+	row, ok := m.selected()
+	if !ok {
+		return nil
+	}
+	row.IsStarred = !row.IsStarred
+
+	postID, starred := row.Post.ID, row.IsStarred
+	if starred {
+		m.status = "Starred " + row.Post.Title
+	} else {
+		m.status = "Unstarred " + row.Post.Title
+	}
+
+	return m.exec(func(db database.Querier) error {
+		if !starred {
+			_, err := db.UnstarPost(
+				context.Background(),
+				database.UnstarPostParams{
+					UserID: m.user.ID,
+					PostID: postID,
+				},
+			)
+			return err
+		}
+
+		return db.StarPost(
+			context.Background(),
+			database.StarPostParams{
+				UserID:  m.user.ID,
+				PostID:  postID,
+				SavedAt: time.Now(),
+			},
+		)
+	})
+}
+
+// openPost opens the selected post in the browser and marks it as read.
+func (m *reader) openPost() tea.Cmd {
+	row, ok := m.selected()
+	if !ok {
+		return nil
+	}
+
+	if err := m.open(row.Post.Url); err != nil {
+		m.status = "Error: " + err.Error()
+		return nil
+	}
+
+	return m.markRead()
+}
+
+// exec runs a query in the background, reporting only its error.
+func (m *reader) exec(query func(db database.Querier) error) tea.Cmd {
+	return func() tea.Msg {
+		if err := query(m.s.db); err != nil {
+			return readerErrMsg{err: err}
+		}
+		return nil
+	}
+}
+
+func (m *reader) tick() tea.Cmd {
+	return tea.Tick(m.refresh, func(t time.Time) tea.Msg {
+		return readerTickMsg(t)
+	})
+}
+
+// loadSources lists the user's tags and followed feeds for the sidebar.
+func (m *reader) loadSources() tea.Cmd {
+	return func() tea.Msg {
+		tags, err := m.s.db.GetTagsForUser(context.Background(), m.user.ID)
+		if err != nil {
+			return readerSourcesMsg{err: err}
+		}
+
+		follows, err := m.s.db.GetFeedFollowsForUser(
+			context.Background(),
+			database.GetFeedFollowsForUserParams{UserID: m.user.ID},
+		)
+		if err != nil {
+			return readerSourcesMsg{err: err}
+		}
+
+		sources := []source{{label: "All posts"}}
+		for _, tag := range tags {
+			sources = append(sources, source{label: "#" + tag.Name, tag: tag.Name})
+		}
+		for _, follow := range follows {
+			sources = append(sources, source{label: follow.FeedName, feedURL: follow.FeedUrl})
+		}
+
+		return readerSourcesMsg{sources: sources}
+	}
+}
+
+// loadPosts loads the posts of the selected source. Refreshes keep the
+// selection and report how many posts arrived since the last load.
+func (m *reader) loadPosts(refresh bool) tea.Cmd {
+	src := m.sources[m.sourceIndex]
+	params := database.GetPostsForUserParams{
+		SortBy:   "fetched",
+		UserID:   m.user.ID,
+		FeedUrl:  nullString(src.feedURL),
+		Tag:      nullString(src.tag),
+		MaxPosts: readerPostLimit,
+	}
+	if m.unreadOnly {
+		params.IsRead.Valid = true
+	}
+
+	return func() tea.Msg {
+		posts, err := m.s.db.GetPostsForUser(context.Background(), params)
+		return readerPostsMsg{source: src, posts: posts, refresh: refresh, err: err}
+	}
+}
+
+// setSources replaces the sidebar entries, keeping the selected one if it's
+// still there. Posts are reloaded when it isn't.
+func (m *reader) setSources(msg readerSourcesMsg) tea.Cmd {
+	if msg.err != nil {
+		m.status = "Error: " + msg.err.Error()
+		return nil
+	}
+
+	selected := m.sources[m.sourceIndex]
+	m.sources = msg.sources
+
+	if index := slices.Index(m.sources, selected); index >= 0 {
+		m.sourceIndex = index
+		return nil
+	}
+
+	m.sourceIndex = 0
+	m.postIndex = 0
+	return m.loadPosts(false)
+}
+
+func (m *reader) setPosts(msg readerPostsMsg) {
+	if msg.source != m.sources[m.sourceIndex] {
+		return
+	}
+	if msg.err != nil {
+		m.status = "Error: " + msg.err.Error()
+		return
+	}
+
+	var selectedID uuid.UUID
+	if row, ok := m.selected(); ok {
+		selectedID = row.Post.ID
+	}
+
+	if msg.refresh {
+		newPosts := 0
+		for _, row := range msg.posts {
+			if !slices.ContainsFunc(m.posts, func(old database.GetPostsForUserRow) bool { return old.Post.ID == row.Post.ID }) {
+				newPosts++
+			}
+		}
+		if newPosts > 0 && len(m.posts) > 0 {
+			m.status = fmt.Sprintf("%d new post(s)", newPosts)
+		}
+	}
+
+	m.posts = msg.posts
+
+	index := slices.IndexFunc(m.posts, func(row database.GetPostsForUserRow) bool { return row.Post.ID == selectedID })
+	if !msg.refresh || index < 0 {
+		index = min(m.postIndex, max(len(m.posts)-1, 0))
+		m.previewOffset = 0
+	}
+	m.postIndex = index
+}
+
+// scroll keeps the selected sidebar entry and post inside their panes.
+func (m *reader) scroll() {
+	height := m.paneHeight()
+	m.sourceOffset = scrollTo(m.sourceOffset, m.sourceIndex, height)
+	m.postOffset = scrollTo(m.postOffset, m.postIndex, height-1)
+}
+
+func scrollTo(offset, selected, height int) int {
+	height = max(height, 1)
+	if selected < offset {
+		return selected
+	}
+	if selected >= offset+height {
+		return selected - height + 1
+	}
+	return offset
+}
+
+// paneHeight is the number of lines inside each pane, leaving room for the
+// borders and the status bar.
+func (m *reader) paneHeight() int {
+	return max(m.height-3, 1)
+}
+
+// paneWidths splits the terminal between the sidebar, the post list and the
+// preview, not counting their borders.
+func (m *reader) paneWidths() (int, int, int) {
+	available := max(m.width-6, 3*minColumnWidth)
+	sidebar := min(max(available/5, minColumnWidth), 30)
+	posts := max((available-sidebar)*2/5, minColumnWidth)
+	preview := max(available-sidebar-posts, minColumnWidth)
+	return sidebar, posts, preview
+}
+
+func (m *reader) View() string {
+	if m.width == 0 {
+		return ""
+	}
+
+	sidebarWidth, postsWidth, previewWidth := m.paneWidths()
+	height := m.paneHeight()
+
+	panes := lipgloss.JoinHorizontal(lipgloss.Top,
+		m.pane(paneSidebar, m.sidebarLines(sidebarWidth, height), sidebarWidth, height),
+		m.pane(panePosts, m.postLines(postsWidth, height), postsWidth, height),
+		m.pane(panePreview, m.previewLines(previewWidth, height), previewWidth, height),
+	)
+
+	status := readerHelp
+	if m.status != "" {
+		status = m.status + " · " + readerHelp
+	}
+	status = dimStyle.Render(runewidth.Truncate(status, max(m.width, 1), "…"))
+
+	return panes + "\n" + status
+}
+
+func (m *reader) pane(index int, lines []string, width, height int) string {
+	style := paneStyle
+	if index == m.focus {
+		style = focusedPaneStyle
+	}
+
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+
+	return style.Width(width).Height(height).Render(strings.Join(lines[:height], "\n"))
+}
+
+// listLine truncates text to the pane's width, highlighting it when selected.
+func (m *reader) listLine(text string, width int, selected bool, style lipgloss.Style) string {
+	text = runewidth.FillRight(runewidth.Truncate(text, width, "…"), width)
+	if selected {
+		return selectedStyle.Render(text)
+	}
+	return style.Render(text)
+}
+
+func (m *reader) sidebarLines(width, height int) []string {
+	var lines []string
+	for i := m.sourceOffset; i < len(m.sources) && len(lines) < height; i++ {
+		lines = append(lines, m.listLine(m.sources[i].label, width, i == m.sourceIndex, lipgloss.NewStyle()))
+	}
+	return lines
+}
+
+func (m *reader) postLines(width, height int) []string {
+	unread := 0
+	for _, row := range m.posts {
+		if !row.IsRead {
+			unread++
+		}
+	}
+
+	title := fmt.Sprintf("%s · %d unread", m.sources[m.sourceIndex].label, unread)
+	if m.unreadOnly {
+		title += " (unread only)"
+	}
+	lines := []string{boldStyle.Render(runewidth.Truncate(title, width, "…"))}
+
+	if len(m.posts) == 0 {
+		return append(lines, dimStyle.Render("No posts to show"))
+	}
+
+	for i := m.postOffset; i < len(m.posts) && len(lines) < height; i++ {
+		row := m.posts[i]
+
+		marker, style := "● ", unreadStyle
+		if row.IsRead {
+			marker, style = "  ", dimStyle
+		}
+		if row.IsStarred {
+			marker = "★ "
+		}
+
+		lines = append(lines, m.listLine(marker+plainText(row.Post.Title), width, i == m.postIndex, style))
+	}
+
+	return lines
+}
+
+// previewLines renders the selected post: its title, feed and date, link,
+// and its content or description as text.
+func (m *reader) previewLines(width, height int) []string {
+	row, ok := m.selected()
+	if !ok {
+		return nil
+	}
+	post := newPostRow(row.Post, row.FeedName)
+
+	var lines []string
+	for _, line := range wrap(plainText(post.Title), width) {
+		lines = append(lines, boldStyle.Render(line))
+	}
+
+	meta := post.Feed
+	if post.PublishedAt != nil {
+		meta += " · " + post.PublishedAt.Local().Format("2006-01-02 15:04")
+	}
+	lines = append(lines,
+		dimStyle.Render(runewidth.Truncate(meta, width, "…")),
+		linkStyle.Render(runewidth.Truncate(post.URL, width, "…")),
+	)
+
+	body := row.Post.Content.String
+	if !row.Post.Content.Valid && post.Description != nil {
+		body = *post.Description
+	}
+	for _, paragraph := range strings.Split(htmlToText(body), "\n\n") {
+		lines = append(lines, "")
+		lines = append(lines, wrap(paragraph, width)...)
+	}
+
+	offset := min(m.previewOffset, max(len(lines)-height, 0))
+	return lines[offset:]
+}
+
+// openInBrowser opens url with $BROWSER, or the system's default browser.
+func openInBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch browser := strings.TrimSpace(os.Getenv("BROWSER")); {
+	case browser != "":
+		cmd = exec.Command(browser, url)
+	case runtime.GOOS == "darwin":
+		cmd = exec.Command("open", url)
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+
+	return nil
+}
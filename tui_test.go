@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thihxm/gator/internal/database"
+)
+
+// newTestReader opens the reader as alice, on the feeds of newTestFeeds with
+// the Go Blog tagged "lang", and returns it with the URLs it opened.
+func newTestReader(t *testing.T) (*reader, *state, *feedServer, *[]string) {
+	t.Helper()
+
+	s, server, goURL, _ := newTestFeeds(t)
+	mustRun(t, s, "tag", goURL, "lang")
+
+	user, err := s.db.GetUser(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opened []string
+	m := newReader(s, user, time.Millisecond)
+	m.open = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+
+	send(t, m, tea.WindowSizeMsg{Width: 120, Height: 30})
+	send(t, m, runCmd(m.Init())...)
+
+	return m, s, server, &opened
+}
+
+// send passes msgs to the reader and runs the commands it returns, the way
+// bubbletea would, except that the refresh timer never fires.
+func send(t *testing.T, m *reader, msgs ...tea.Msg) {
+	t.Helper()
+
+	for len(msgs) > 0 {
+		_, cmd := m.Update(msgs[0])
+		msgs = append(msgs[1:], runCmd(cmd)...)
+	}
+}
+
+func runCmd(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+
+	switch msg := cmd().(type) {
+	case nil, readerTickMsg, tea.QuitMsg:
+		return nil
+	case tea.BatchMsg:
+		var msgs []tea.Msg
+		for _, cmd := range msg {
+			msgs = append(msgs, runCmd(cmd)...)
+		}
+		return msgs
+	default:
+		return []tea.Msg{msg}
+	}
+}
+
+func key(name string) tea.KeyMsg {
+	switch name {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "shift+tab":
+		return tea.KeyMsg{Type: tea.KeyShiftTab}
+	default:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name)}
+	}
+}
+
+// userPost returns the post with url as the reader's user sees it.
+func userPost(t *testing.T, m *reader, url string) database.GetPostsForUserRow {
+	t.Helper()
+
+	posts, err := m.s.db.GetPostsForUser(
+		context.Background(),
+		database.GetPostsForUserParams{SortBy: "fetched", UserID: m.user.ID, MaxPosts: 100},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range posts {
+		if row.Post.Url == url {
+			return row
+		}
+	}
+
+	t.Fatalf("no post with URL %s", url)
+	return database.GetPostsForUserRow{}
+}
+
+func TestReader(t *testing.T) {
+	m, _, _, opened := newTestReader(t)
+
+	if len(m.posts) != 4 {
+		t.Fatalf("expected 4 posts, got %d", len(m.posts))
+	}
+	assertContains(t, m.View(), "All posts · 4 unread", "#lang", "Go Blog", "Rust Blog", "Ownership", "Generics in Go")
+
+	first := m.posts[0].Post
+	send(t, m, key("enter"))
+	if !userPost(t, m, first.Url).IsRead {
+		t.Errorf("expected %s to be marked as read", first.Title)
+	}
+	if m.focus != panePreview {
+		t.Errorf("expected the preview to be focused, got pane %d", m.focus)
+	}
+	assertContains(t, m.View(), "All posts · 3 unread", first.Url)
+
+	send(t, m, key("s"))
+	if !userPost(t, m, first.Url).IsStarred {
+		t.Errorf("expected %s to be starred", first.Title)
+	}
+	assertContains(t, m.View(), "★ "+first.Title, "Starred "+first.Title)
+
+	send(t, m, key("s"))
+	if userPost(t, m, first.Url).IsStarred {
+		t.Errorf("expected %s to be unstarred", first.Title)
+	}
+
+	send(t, m, key("o"))
+	if len(*opened) != 1 || (*opened)[0] != first.Url {
+		t.Errorf("expected %s to be opened, got %v", first.Url, *opened)
+	}
+
+	send(t, m, key("n"))
+	second := m.posts[1].Post
+	if m.postIndex != 1 || !userPost(t, m, second.Url).IsRead {
+		t.Errorf("expected n to select and read %s", second.Title)
+	}
+
+	send(t, m, key("u"))
+	if len(m.posts) != 2 {
+		t.Errorf("expected 2 unread posts, got %d", len(m.posts))
+	}
+	assertContains(t, m.View(), "(unread only)")
+
+	send(t, m, key("n"), key("n"))
+	assertContains(t, m.View(), "No more unread posts")
+}
+
+func TestReaderSidebar(t *testing.T) {
+	m, _, _, _ := newTestReader(t)
+
+	send(t, m, key("shift+tab"), key("j"))
+	assertContains(t, m.View(), "#lang · 2 unread", "Generics in Go", "Go modules")
+	assertNotContains(t, m.View(), "Ownership")
+
+	send(t, m, key("j"), key("j"))
+	assertContains(t, m.View(), "Rust Blog · 2 unread", "Ownership")
+	assertNotContains(t, m.View(), "Go modules")
+
+	// Going past the last entry keeps it selected.
+	send(t, m, key("j"))
+	if m.sources[m.sourceIndex].label != "Rust Blog" {
+		t.Errorf("expected Rust Blog to stay selected, got %s", m.sources[m.sourceIndex].label)
+	}
+}
+
+func TestReaderPreview(t *testing.T) {
+	m, _, _, _ := newTestReader(t)
+
+	for m.posts[m.postIndex].Post.Title != "Generics in Go" {
+		send(t, m, key("j"))
+	}
+
+	// Content is shown when the feed has it, the description otherwise.
+	assertContains(t, m.View(), "Go Blog · ", "Write generic functions with type parameters")
+	assertNotContains(t, m.View(), "Type parameters explained")
+
+	send(t, m, key("k"))
+	assertContains(t, m.View(), "Managing dependencies")
+}
+
+func TestReaderRefresh(t *testing.T) {
+	m, s, server, _ := newTestReader(t)
+
+	send(t, m, key("j"))
+	selected := m.posts[m.postIndex].Post.ID
+
+	server.setFeed("/go", "Go Blog",
+		testItem{
+			title:       "Range over functions",
+			link:        "https://example.com/go/iterators",
+			description: "Iterators in Go",
+			published:   time.Now(),
+		},
+	)
+	fetchAll(t, s)
+
+	send(t, m, readerTickMsg(time.Now()))
+	if len(m.posts) != 5 {
+		t.Fatalf("expected 5 posts after refreshing, got %d", len(m.posts))
+	}
+	if m.posts[m.postIndex].Post.ID != selected {
+		t.Error("expected the selected post to stay selected")
+	}
+	assertContains(t, m.View(), "1 new post(s)", "Range over functions")
+
+	mustRun(t, s, "unfollow", server.URL+"/rust")
+	send(t, m, readerTickMsg(time.Now()))
+	assertNotContains(t, m.View(), "Rust Blog", "Ownership")
+}